@@ -0,0 +1,100 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AgeKey condition reasons.
+const (
+	ReasonKeyGenerated  = "KeyGenerated"
+	ReasonKeyAdopted    = "KeyAdopted"
+	ReasonSecretMissing = "SecretMissing"
+	ReasonKeyInvalid    = "KeyInvalid"
+)
+
+// AgeKeyAnnotation names the AgeKey that generated or adopted a key Secret. Key
+// Secrets get no owner reference, so deleting the AgeKey never deletes the key.
+const AgeKeyAnnotation = "security.age.io/agekey"
+
+// AgeKeySpec defines the desired state of an AgeKey.
+type AgeKeySpec struct {
+	// Lifecycle state of the key, written to the key Secret.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=active;retired;revoked
+	// +kubebuilder:default=active
+	State KeyState `json:"state,omitempty"`
+}
+
+// AgeKeyStatus defines the observed state of an AgeKey.
+type AgeKeyStatus struct {
+	// +kubebuilder:validation:Optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Public recipient (age1...) to seal new data to.
+	// +kubebuilder:validation:Optional
+	Recipient string `json:"recipient,omitempty"`
+	// Name of the key Secret holding the private identity.
+	// +kubebuilder:validation:Optional
+	SecretName string `json:"secretName,omitempty"`
+	// When the key Secret was created.
+	// +kubebuilder:validation:Optional
+	CreatedAt *metav1.Time `json:"createdAt,omitempty"`
+	// Lifecycle state as applied to the key Secret.
+	// +kubebuilder:validation:Optional
+	State KeyState `json:"state,omitempty"`
+	// Number of SealedAges with at least one field sealed to this key.
+	// +kubebuilder:validation:Optional
+	SealedAges int32 `json:"sealedAges"`
+	// Standard conditions: Ready.
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=agekeys,scope=Namespaced,shortName=ak
+// +kubebuilder:printcolumn:name="Recipient",type=string,JSONPath=`.status.recipient`
+// +kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.state`
+// +kubebuilder:printcolumn:name="SealedAges",type=integer,JSONPath=`.status.sealedAges`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Created",type=date,JSONPath=`.status.createdAt`
+
+// AgeKey is an AGE X25519 key. It manages the key Secret of the same name in the
+// key namespace and reports its recipient, state and usage.
+type AgeKey struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AgeKeySpec   `json:"spec,omitempty"`
+	Status AgeKeyStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AgeKeyList contains a list of AgeKey.
+type AgeKeyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AgeKey `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AgeKey{}, &AgeKeyList{})
+}
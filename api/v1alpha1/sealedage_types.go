@@ -21,12 +21,228 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// SealedAgeTemplate defines Secret template settings (you currently use only .type).
+// Condition types reported in SealedAgeStatus.Conditions.
+const (
+	// ConditionReady summarizes the other conditions; True once the Secret is in sync.
+	ConditionReady = "Ready"
+	// ConditionKeysAvailable reports whether AGE key Secrets were found in the key namespace.
+	ConditionKeysAvailable = "KeysAvailable"
+	// ConditionDecrypted reports whether every field in spec.encryptedData could be decrypted.
+	ConditionDecrypted = "Decrypted"
+	// ConditionSecretSynced reports whether the generated Secret matches the decrypted data.
+	ConditionSecretSynced = "SecretSynced"
+	// ConditionKeysCurrent reports whether every field was decrypted with an active key.
+	// It is informational and does not affect Ready.
+	ConditionKeysCurrent = "KeysCurrent"
+	// ConditionResealed reports whether spec.resealPolicy could be applied. It is
+	// informational and only set when resealing is enabled.
+	ConditionResealed = "Resealed"
+)
+
+// Condition reasons (machine-readable, CamelCase).
+const (
+	ReasonSucceeded         = "Succeeded"
+	ReasonKeysFound         = "KeysFound"
+	ReasonNoKeysFound       = "NoKeysFound"
+	ReasonKeyListFailed     = "KeyListFailed"
+	ReasonDecryptFailed     = "DecryptFailed"
+	ReasonSecretReadFailed  = "SecretReadFailed"
+	ReasonSecretWriteFailed = "SecretWriteFailed"
+	ReasonSecretConflict    = "SecretConflict"
+	ReasonKeysNotRequired   = "KeysNotRequired"
+	ReasonPassphraseMissing = "PassphraseMissing"
+	ReasonKeyRevoked        = "KeyRevoked"
+	ReasonRetiredKeyInUse   = "RetiredKeyInUse"
+	ReasonResealFailed      = "ResealFailed"
+	ReasonExportConflict    = "ExportConflict"
+)
+
+// ManagedFieldsAnnotation lists (comma-separated) the Secret data keys written by
+// the owning SealedAge, so keys removed from spec.encryptedData can be pruned.
+const ManagedFieldsAnnotation = "security.age.io/managed-fields"
+
+// ManagedByLabel is set to ManagedByValue on every Secret and ConfigMap the
+// operator generates. The operator only caches ConfigMaps carrying it.
+const (
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	ManagedByValue = "sealed-age-operator"
+)
+
+// RecipientsLabel set to "true" marks the published recipients ConfigMaps, so
+// they can be cleaned up when the feature is turned off or the name changes.
+const RecipientsLabel = "security.age.io/recipients"
+
+// RecipientsConfigMapKey is the data key of the recipients ConfigMap holding
+// the recipients file.
+const RecipientsConfigMapKey = "recipients"
+
+// SecretFinalizer is added to every SealedAge so the deletion policy can be
+// applied to the generated Secret before the SealedAge goes away.
+const SecretFinalizer = "security.age.io/secret"
+
+// KeyFinalizer is added to every key Secret and only released once no
+// SealedAge ciphertext is sealed to the key any more.
+const KeyFinalizer = "security.age.io/key-in-use"
+
+// RetainSecretAnnotation set to "true" on a SealedAge keeps (orphans) the generated
+// Secret on deletion regardless of spec.deletionPolicy, e.g. during a CRD uninstall.
+const RetainSecretAnnotation = "security.age.io/retain-secret"
+
+// ManagedLabelsAnnotation and ManagedAnnotationsAnnotation list the label and
+// annotation keys copied from spec.template.metadata, so removed ones can be pruned.
+const (
+	ManagedLabelsAnnotation      = "security.age.io/managed-labels"
+	ManagedAnnotationsAnnotation = "security.age.io/managed-annotations"
+)
+
+// KeyTypeAnnotation on a key Secret overrides the detected type of its private
+// key: KeyTypeX25519 or KeyTypeSSH. A "type" data field takes precedence.
+const KeyTypeAnnotation = "security.age.io/key-type"
+
+// Key types accepted in KeyTypeAnnotation.
+const (
+	KeyTypeX25519 = "x25519"
+	KeyTypeSSH    = "ssh"
+)
+
+// KeyStateAnnotation sets the lifecycle state (KeyState) of a key Secret.
+// Without it, the legacy "active" annotation written by the rotation job is
+// read: "false" means retired, anything else active.
+const KeyStateAnnotation = "security.age.io/key-state"
+
+// LegacyActiveAnnotation is set to "true" on new keys and to "false" when
+// the key is rotated out (first by the rotation job, now by the operator).
+const LegacyActiveAnnotation = "active"
+
+// KeepKeyAnnotation set to "true" on a key Secret protects it from the key pruner.
+const KeepKeyAnnotation = "security.age.io/keep"
+
+// RotatedKeyLabel set to "true" marks the key Secrets created by key rotation.
+// Only those are rotated out and pruned; keys declared with an AgeKey never are.
+const RotatedKeyLabel = "security.age.io/rotated"
+
+// KeyRetiredAtAnnotation records (RFC 3339) when the operator retired a key.
+const KeyRetiredAtAnnotation = "security.age.io/retired-at"
+
+// KeyState is the lifecycle state of a key Secret.
+type KeyState string
+
+const (
+	// KeyStateActive keys are used for decryption and published as recipients.
+	KeyStateActive KeyState = "active"
+	// KeyStateRetired keys still decrypt, but new data should not be sealed to them.
+	KeyStateRetired KeyState = "retired"
+	// KeyStateRevoked keys are never used; the Secret is kept for auditing.
+	KeyStateRevoked KeyState = "revoked"
+)
+
+// MergePolicy controls how decrypted fields are combined with existing Secret data.
+// +kubebuilder:validation:Enum=Replace;Merge
+type MergePolicy string
+
+const (
+	// MergePolicyReplace makes the Secret contain exactly the decrypted fields.
+	MergePolicyReplace MergePolicy = "Replace"
+	// MergePolicyMerge keeps foreign keys but still prunes keys this SealedAge wrote before.
+	MergePolicyMerge MergePolicy = "Merge"
+)
+
+// AdoptionPolicy controls whether an existing Secret not owned by the SealedAge is taken over.
+// +kubebuilder:validation:Enum=Never;IfUnowned;Always
+type AdoptionPolicy string
+
+const (
+	// AdoptionNever refuses to touch any Secret the SealedAge does not already control.
+	AdoptionNever AdoptionPolicy = "Never"
+	// AdoptionIfUnowned takes over Secrets without a controller owner reference.
+	AdoptionIfUnowned AdoptionPolicy = "IfUnowned"
+	// AdoptionAlways takes over the Secret even if another controller owns it.
+	AdoptionAlways AdoptionPolicy = "Always"
+)
+
+// DeletionPolicy controls what happens to the generated Secret when the SealedAge is deleted.
+// +kubebuilder:validation:Enum=Delete;Orphan
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete deletes the Secret together with the SealedAge.
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyOrphan removes the owner reference and keeps the Secret.
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+)
+
+// ResealPolicy controls whether fields are re-encrypted to the current recipients.
+// +kubebuilder:validation:Enum=Never;InPlace;Export
+type ResealPolicy string
+
+const (
+	// ResealNever leaves encryptedData as it is.
+	ResealNever ResealPolicy = "Never"
+	// ResealInPlace writes the resealed ciphertext back to spec.encryptedData.
+	ResealInPlace ResealPolicy = "InPlace"
+	// ResealExport writes a resealed SealedAge manifest to the ConfigMap
+	// <name>-resealed, to be committed back to git.
+	ResealExport ResealPolicy = "Export"
+)
+
+// ResealedGenerationAnnotation and ResealedRecipientsAnnotation record on the
+// export ConfigMap which generation was resealed, and to which recipients.
+const (
+	ResealedGenerationAnnotation = "security.age.io/resealed-generation"
+	ResealedRecipientsAnnotation = "security.age.io/resealed-recipients"
+)
+
+// SealedRecipientsAnnotation lists (comma-separated) the recipients the
+// encryptedData of a SealedAge manifest is sealed to. age doesn't record X25519
+// recipients in the file, so `sealage seal` and Export reseals write it down
+// for `sealage edit`.
+const SealedRecipientsAnnotation = "security.age.io/sealed-recipients"
+
+// SealedAgeTemplateMetadata defines metadata for the generated Secret.
+type SealedAgeTemplateMetadata struct {
+	// Secret name; defaults to the SealedAge name.
+	// +kubebuilder:validation:Optional
+	Name string `json:"name,omitempty"`
+
+	// Labels added to the Secret.
+	// +kubebuilder:validation:Optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations added to the Secret.
+	// +kubebuilder:validation:Optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// SealedAgeTemplate defines Secret template settings.
 type SealedAgeTemplate struct {
+	// Metadata (name, labels, annotations) of the generated Secret.
+	// +kubebuilder:validation:Optional
+	Metadata SealedAgeTemplateMetadata `json:"metadata,omitempty"`
+
 	// Default to Opaque if not specified.
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:default=Opaque
 	Type string `json:"type,omitempty"`
+
+	// How decrypted fields are applied to the Secret (Replace or Merge).
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=Replace
+	MergePolicy MergePolicy `json:"mergePolicy,omitempty"`
+
+	// Immutable marks the Secret immutable; it is recreated when its data changes.
+	// +kubebuilder:validation:Optional
+	Immutable *bool `json:"immutable,omitempty"`
+}
+
+// PassphraseRef selects the passphrase of a field encrypted with `age -p`.
+type PassphraseRef struct {
+	// Name of the Secret in the operator's key namespace.
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Key of the passphrase in the Secret; defaults to "passphrase".
+	// +kubebuilder:validation:Optional
+	Key string `json:"key,omitempty"`
 }
 
 // SealedAgeSpec defines the desired state of the SealedAge resource.
@@ -35,13 +251,34 @@ type SealedAgeSpec struct {
 	// +kubebuilder:validation:Required
 	EncryptedData map[string]string `json:"encryptedData"`
 
+	// Passphrases for encryptedData fields encrypted with `age -p` (scrypt),
+	// keyed by field name. These fields do not need a key Secret.
+	// +kubebuilder:validation:Optional
+	PassphraseRefs map[string]PassphraseRef `json:"passphraseRefs,omitempty"`
+
 	// Secret template (e.g., Type: Opaque).
 	// +kubebuilder:validation:Optional
 	Template SealedAgeTemplate `json:"template,omitempty"`
 
-	// Optional: list of recipients.
+	// Recipients (age1..., ssh-ed25519/ssh-rsa or plugin recipients) fields are
+	// resealed to in addition to the active keys, e.g. a break-glass key.
 	// +kubebuilder:validation:Optional
 	Recipients []string `json:"recipients,omitempty"`
+
+	// Whether fields not sealed to the current recipients are resealed after decryption.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=Never
+	ResealPolicy ResealPolicy `json:"resealPolicy,omitempty"`
+
+	// Whether an existing Secret with the target name may be taken over.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=IfUnowned
+	AdoptionPolicy AdoptionPolicy `json:"adoptionPolicy,omitempty"`
+
+	// What happens to the Secret when the SealedAge is deleted.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=Delete
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
 }
 
 // SealedAgeStatus defines observed state and metadata for the SealedAge resource.
@@ -50,7 +287,16 @@ type SealedAgeStatus struct {
 	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 	// +kubebuilder:validation:Optional
 	SecretName string `json:"secretName,omitempty"`
+	// Key Secrets used to decrypt the current encryptedData.
+	// +kubebuilder:validation:Optional
+	KeySecrets []string `json:"keySecrets,omitempty"`
+	// Recipients encryptedData was last resealed to (resealPolicy InPlace or Export).
+	// +kubebuilder:validation:Optional
+	SealedRecipients []string `json:"sealedRecipients,omitempty"`
+	// Standard conditions: Ready, KeysAvailable, Decrypted, SecretSynced, KeysCurrent.
 	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=type
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
@@ -60,6 +306,8 @@ type SealedAgeStatus struct {
 // +kubebuilder:resource:path=sealedages,scope=Namespaced,shortName=sea
 // Printer columns (shown in `kubectl get sealedages`).
 // +kubebuilder:printcolumn:name="Secret",type=string,JSONPath=`.status.secretName`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`
 // +kubebuilder:printcolumn:name="Age",type=integer,JSONPath=`.metadata.generation`
 type SealedAge struct {
 	metav1.TypeMeta   `json:",inline"`
@@ -25,6 +25,121 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AgeKey) DeepCopyInto(out *AgeKey) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AgeKey.
+func (in *AgeKey) DeepCopy() *AgeKey {
+	if in == nil {
+		return nil
+	}
+	out := new(AgeKey)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AgeKey) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AgeKeyList) DeepCopyInto(out *AgeKeyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AgeKey, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AgeKeyList.
+func (in *AgeKeyList) DeepCopy() *AgeKeyList {
+	if in == nil {
+		return nil
+	}
+	out := new(AgeKeyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AgeKeyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AgeKeySpec) DeepCopyInto(out *AgeKeySpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AgeKeySpec.
+func (in *AgeKeySpec) DeepCopy() *AgeKeySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AgeKeySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AgeKeyStatus) DeepCopyInto(out *AgeKeyStatus) {
+	*out = *in
+	if in.CreatedAt != nil {
+		in, out := &in.CreatedAt, &out.CreatedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AgeKeyStatus.
+func (in *AgeKeyStatus) DeepCopy() *AgeKeyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AgeKeyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PassphraseRef) DeepCopyInto(out *PassphraseRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PassphraseRef.
+func (in *PassphraseRef) DeepCopy() *PassphraseRef {
+	if in == nil {
+		return nil
+	}
+	out := new(PassphraseRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SealedAge) DeepCopyInto(out *SealedAge) {
 	*out = *in
@@ -94,7 +209,14 @@ func (in *SealedAgeSpec) DeepCopyInto(out *SealedAgeSpec) {
 			(*out)[key] = val
 		}
 	}
-	out.Template = in.Template
+	if in.PassphraseRefs != nil {
+		in, out := &in.PassphraseRefs, &out.PassphraseRefs
+		*out = make(map[string]PassphraseRef, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.Template.DeepCopyInto(&out.Template)
 	if in.Recipients != nil {
 		in, out := &in.Recipients, &out.Recipients
 		*out = make([]string, len(*in))
@@ -115,6 +237,16 @@ func (in *SealedAgeSpec) DeepCopy() *SealedAgeSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SealedAgeStatus) DeepCopyInto(out *SealedAgeStatus) {
 	*out = *in
+	if in.KeySecrets != nil {
+		in, out := &in.KeySecrets, &out.KeySecrets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SealedRecipients != nil {
+		in, out := &in.SealedRecipients, &out.SealedRecipients
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]v1.Condition, len(*in))
@@ -137,6 +269,12 @@ func (in *SealedAgeStatus) DeepCopy() *SealedAgeStatus {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SealedAgeTemplate) DeepCopyInto(out *SealedAgeTemplate) {
 	*out = *in
+	in.Metadata.DeepCopyInto(&out.Metadata)
+	if in.Immutable != nil {
+		in, out := &in.Immutable, &out.Immutable
+		*out = new(bool)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SealedAgeTemplate.
@@ -148,3 +286,32 @@ func (in *SealedAgeTemplate) DeepCopy() *SealedAgeTemplate {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SealedAgeTemplateMetadata) DeepCopyInto(out *SealedAgeTemplateMetadata) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SealedAgeTemplateMetadata.
+func (in *SealedAgeTemplateMetadata) DeepCopy() *SealedAgeTemplateMetadata {
+	if in == nil {
+		return nil
+	}
+	out := new(SealedAgeTemplateMetadata)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -9,19 +9,27 @@ package main
 import (
 	"flag"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
 	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+	"github.com/callmewhatuwant/sealed-age-operator/internal/agecrypt"
 	"github.com/callmewhatuwant/sealed-age-operator/internal/controller"
 )
 
@@ -39,21 +47,59 @@ func main() {
 	var (
 		metricsAddr          string
 		probeAddr            string
+		recipientsAddr       string
 		enableLeaderElection bool
 		leaderNS             string
 
 		// key Secret Discovery
 		keyNS, keyLabelKey, keyLabelVal string
+		keyFields                       string
+		maxScryptWorkFactor             int
+		pluginPath                      string
+		pluginTimeout                   time.Duration
+		keyFinalizer                    bool
+
+		// recipients ConfigMaps
+		recipientsConfigMap, recipientsNSSelector string
+
+		// key rotation and pruning
+		keyRotationInterval, keyRetention time.Duration
+		keyPruneMode                      string
+		keyPruneDryRun                    bool
 	)
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metrics endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
+	flag.StringVar(&recipientsAddr, "recipients-bind-address", "0",
+		"The address the public recipients endpoint binds to. Use \"0\" to disable it.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false, "Enable leader election for controller manager.")
 	flag.StringVar(&leaderNS, "leader-election-namespace", "",
 		"Namespace for the leader election Lease (defaults to POD_NAMESPACE or sealed-age-system).")
 	flag.StringVar(&keyNS, "key-namespace", "sealed-age-system", "Namespace containing AGE key Secrets.")
 	flag.StringVar(&keyLabelKey, "key-label-key", "app", "Label key for AGE key Secrets.")
 	flag.StringVar(&keyLabelVal, "key-label-val", "age-key", "Label value for AGE key Secrets.")
+	flag.StringVar(&keyFields, "key-fields", controller.DefaultKeyField,
+		"Comma-separated key Secret data fields holding private identities.")
+	flag.IntVar(&maxScryptWorkFactor, "max-scrypt-work-factor", controller.DefaultMaxScryptWorkFactor,
+		"Maximum scrypt work factor (log2 N) accepted for passphrase-encrypted fields.")
+	flag.StringVar(&pluginPath, "age-plugin-path", "",
+		"Directories (PATH-style list) searched for age-plugin-* binaries before $PATH.")
+	flag.DurationVar(&pluginTimeout, "age-plugin-timeout", 30*time.Second,
+		"How long an age plugin may take to unwrap a file before it is killed.")
+	flag.BoolVar(&keyFinalizer, "key-finalizer", true,
+		"Keep key Secrets from being deleted while SealedAges are sealed to them (false removes the finalizers).")
+	flag.StringVar(&recipientsConfigMap, "recipients-configmap", "",
+		"Name of the ConfigMap with the active recipients kept in every namespace (empty disables it).")
+	flag.StringVar(&recipientsNSSelector, "recipients-namespace-selector", "",
+		"Label selector for the namespaces that get the recipients ConfigMap (empty selects all).")
+	flag.DurationVar(&keyRotationInterval, "key-rotation-interval", 30*24*time.Hour,
+		"How often a new AGE key is generated (0 disables rotation).")
+	flag.DurationVar(&keyRetention, "key-retention", 0,
+		"Minimum age of a key before it is pruned once no SealedAge is sealed to it (0 disables pruning).")
+	flag.StringVar(&keyPruneMode, "key-prune-mode", string(controller.PruneModeDelete),
+		"What happens to unused keys: delete or retire.")
+	flag.BoolVar(&keyPruneDryRun, "key-prune-dry-run", false,
+		"Only report unused keys (events, logs, metrics) instead of pruning them.")
 
 	opts := zap.Options{Development: true}
 	opts.BindFlags(flag.CommandLine)
@@ -71,12 +117,27 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if err := agecrypt.SetPluginPath(filepath.SplitList(pluginPath)...); err != nil {
+		setupLog.Error(err, "invalid age plugin path")
+		os.Exit(1)
+	}
+	if err := agecrypt.SetPluginTimeout(pluginTimeout); err != nil {
+		setupLog.Error(err, "invalid --age-plugin-timeout")
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme: scheme,
 		Metrics: metricsserver.Options{
 			BindAddress: metricsAddr,
 		},
 		HealthProbeBindAddress: probeAddr,
+		// Only the ConfigMaps the operator writes, not every ConfigMap in the cluster.
+		Cache: cache.Options{ByObject: map[client.Object]cache.ByObject{
+			&corev1.ConfigMap{}: {Label: labels.SelectorFromSet(labels.Set{
+				securityv1alpha1.ManagedByLabel: securityv1alpha1.ManagedByValue,
+			})},
+		}},
 
 		// 🔒 Leader Election (Lease in coordination.k8s.io)
 		LeaderElection:          enableLeaderElection,
@@ -88,17 +149,140 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Both controllers read key Secrets; share the parsed identities.
+	keys := controller.NewKeyStore(splitList(keyFields)...)
+
 	if err := (&controller.SealedAgeReconciler{
 		Client:       mgr.GetClient(),
 		Scheme:       mgr.GetScheme(),
 		KeyNamespace: keyNS,
 		KeyLabelKey:  keyLabelKey,
 		KeyLabelVal:  keyLabelVal,
+		KeyFields:    splitList(keyFields),
+		Recorder:     mgr.GetEventRecorderFor("sealedage-controller"),
+		Keys:         keys,
+		APIReader:    mgr.GetAPIReader(),
+
+		MaxScryptWorkFactor: maxScryptWorkFactor,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "SealedAge")
 		os.Exit(1)
 	}
 
+	// Without the AgeKey CRD (e.g. an upgrade that didn't apply it) the key
+	// Secrets are managed on their own instead of failing the cache sync.
+	ageKeys, err := controller.AgeKeysInstalled(mgr.GetRESTMapper())
+	if err != nil {
+		setupLog.Error(err, "unable to check for the AgeKey CRD")
+		os.Exit(1)
+	}
+	if ageKeys {
+		if err := (&controller.AgeKeyReconciler{
+			Client:       mgr.GetClient(),
+			Scheme:       mgr.GetScheme(),
+			KeyNamespace: keyNS,
+			KeyLabelKey:  keyLabelKey,
+			KeyLabelVal:  keyLabelVal,
+			Recorder:     mgr.GetEventRecorderFor("agekey-controller"),
+			Keys:         keys,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "AgeKey")
+			os.Exit(1)
+		}
+	} else {
+		setupLog.Info("AgeKey CRD not installed, managing key Secrets without AgeKeys")
+	}
+
+	if err := (&controller.KeySecretReconciler{
+		Client:       mgr.GetClient(),
+		KeyNamespace: keyNS,
+		KeyLabelKey:  keyLabelKey,
+		KeyLabelVal:  keyLabelVal,
+		Disabled:     !keyFinalizer,
+		Recorder:     mgr.GetEventRecorderFor("keysecret-controller"),
+		Keys:         keys,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "KeySecret")
+		os.Exit(1)
+	}
+
+	if recipientsConfigMap != "" {
+		selector, err := labels.Parse(recipientsNSSelector)
+		if err != nil {
+			setupLog.Error(err, "invalid --recipients-namespace-selector")
+			os.Exit(1)
+		}
+		if err := (&controller.RecipientsConfigMapReconciler{
+			Client:            mgr.GetClient(),
+			KeyNamespace:      keyNS,
+			KeyLabelKey:       keyLabelKey,
+			KeyLabelVal:       keyLabelVal,
+			Name:              recipientsConfigMap,
+			NamespaceSelector: selector,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "RecipientsConfigMap")
+			os.Exit(1)
+		}
+	}
+	// Remove the ConfigMaps of a former --recipients-configmap setting.
+	if err := mgr.Add(&controller.RecipientsConfigMapCleaner{
+		Client: mgr.GetClient(),
+		Name:   recipientsConfigMap,
+	}); err != nil {
+		setupLog.Error(err, "unable to add recipients configmap cleaner")
+		os.Exit(1)
+	}
+
+	if keyRotationInterval > 0 {
+		if err := mgr.Add(&controller.KeyRotator{
+			Client:       mgr.GetClient(),
+			KeyNamespace: keyNS,
+			KeyLabelKey:  keyLabelKey,
+			KeyLabelVal:  keyLabelVal,
+			Interval:     keyRotationInterval,
+			AgeKeys:      ageKeys,
+			Recorder:     mgr.GetEventRecorderFor("key-rotator"),
+		}); err != nil {
+			setupLog.Error(err, "unable to add key rotator")
+			os.Exit(1)
+		}
+	}
+
+	if keyRetention > 0 {
+		mode := controller.PruneMode(keyPruneMode)
+		if mode != controller.PruneModeDelete && mode != controller.PruneModeRetire {
+			setupLog.Error(nil, "invalid --key-prune-mode, use delete or retire", "mode", keyPruneMode)
+			os.Exit(1)
+		}
+		if err := mgr.Add(&controller.KeyPruner{
+			Client:       mgr.GetClient(),
+			KeyNamespace: keyNS,
+			KeyLabelKey:  keyLabelKey,
+			KeyLabelVal:  keyLabelVal,
+			Retention:    keyRetention,
+			Mode:         mode,
+			DryRun:       keyPruneDryRun,
+			Recorder:     mgr.GetEventRecorderFor("key-pruner"),
+			Keys:         keys,
+		}); err != nil {
+			setupLog.Error(err, "unable to add key pruner")
+			os.Exit(1)
+		}
+	}
+
+	if recipientsAddr != "" && recipientsAddr != "0" {
+		if err := mgr.Add(&controller.RecipientsServer{
+			Reader:       mgr.GetClient(),
+			KeyNamespace: keyNS,
+			KeyLabelKey:  keyLabelKey,
+			KeyLabelVal:  keyLabelVal,
+			BindAddress:  recipientsAddr,
+		}); err != nil {
+			setupLog.Error(err, "unable to add recipients server")
+			os.Exit(1)
+		}
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
@@ -114,3 +298,14 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+	"github.com/callmewhatuwant/sealed-age-operator/internal/agecrypt"
+	"github.com/callmewhatuwant/sealed-age-operator/internal/sealage"
+)
+
+const editHeader = `# Edit the Secret below; changed and added fields are resealed, unchanged
+# fields keep their ciphertext. Save an empty file to abort.
+`
+
+func runEdit(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("edit", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: sealage edit -i <identity file> [flags] <sealedage.yaml>")
+		fs.PrintDefaults()
+	}
+	var (
+		recipientsURL, configMap                  string
+		kubeconfig, kubeContext                   string
+		fromCluster                               bool
+		identities, recipientArgs, recipientFiles stringList
+	)
+	fs.Var(&identities, "i", "Identity file (age or unencrypted ssh key) to decrypt with (repeatable).")
+	fs.Var(&identities, "identity", "Identity file (age or unencrypted ssh key) to decrypt with (repeatable).")
+	fs.Var(&recipientArgs, "r", "Reseal every field to this recipient instead of the recorded ones (repeatable).")
+	fs.Var(&recipientArgs, "recipient", "Reseal every field to this recipient instead of the recorded ones (repeatable).")
+	fs.Var(&recipientFiles, "R", "Reseal every field to this recipients file instead of the recorded recipients (repeatable).")
+	fs.Var(&recipientFiles, "recipients-file", "Reseal every field to this recipients file instead of the recorded recipients (repeatable).")
+	fs.StringVar(&recipientsURL, "recipients-url", "",
+		"Reseal every field to the recipients from the operator's recipients endpoint.")
+	fs.BoolVar(&fromCluster, "from-cluster", false,
+		"Reseal every field to the recipients from the operator's recipients ConfigMap in the SealedAge's namespace.")
+	fs.StringVar(&configMap, "recipients-configmap", "age-recipients", "Name of the recipients ConfigMap.")
+	fs.StringVar(&kubeconfig, "kubeconfig", "", "Path to the kubeconfig file (default: $KUBECONFIG or ~/.kube/config).")
+	fs.StringVar(&kubeContext, "context", "", "Kubeconfig context to use.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || len(identities) == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	path := fs.Arg(0)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	sa, err := sealage.ReadSealedAge(f)
+	_ = f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	// Resolve the recipients before editing, so a missing annotation doesn't
+	// throw the edit away.
+	recipients, err := collectRecipients(ctx, recipientArgs, recipientFiles, recipientsURL)
+	if err != nil {
+		return err
+	}
+	if fromCluster {
+		rs, err := clusterRecipients(ctx, kubeconfig, kubeContext, sa.Namespace, configMap)
+		if err != nil {
+			return err
+		}
+		recipients = append(recipients, rs...)
+	}
+	// New recipients replace the recorded ones; spec.recipients are kept.
+	override := len(recipients) > 0
+	if override {
+		recipients = append(recipients, sa.Spec.Recipients...)
+	} else {
+		if recipients, err = sealage.RecipientsOf(sa); err != nil {
+			return fmt.Errorf("%s: %w: pass them with -r, -R or --recipients-url, or use --from-cluster", path, err)
+		}
+	}
+
+	ring := &agecrypt.Keyring{}
+	for _, id := range identities {
+		ids, err := sealage.ReadIdentityFile(id)
+		if err != nil {
+			return err
+		}
+		ring.Add(id, ids...)
+	}
+	before, err := sealage.Unseal(sa, ring)
+	if err != nil {
+		return err
+	}
+
+	plain, err := sealage.MarshalSecret(before)
+	if err != nil {
+		return err
+	}
+	if refs := len(sa.Spec.PassphraseRefs); refs > 0 {
+		plain = append([]byte(fmt.Sprintf("# %d field(s) with a passphraseRef are not shown and stay as they are.\n", refs)), plain...)
+	}
+	plain = append([]byte(editHeader), plain...)
+	edited, err := editTemp(plain)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(edited, plain) && !override {
+		fmt.Fprintln(os.Stderr, "no changes")
+		return nil
+	}
+	if len(bytes.TrimSpace(stripComments(edited))) == 0 {
+		return errors.New("edit aborted, the file is empty")
+	}
+	secrets, err := sealage.ReadSecrets(bytes.NewReader(edited))
+	if err != nil {
+		return err
+	}
+	if len(secrets) != 1 {
+		return errors.New("expected exactly one Secret")
+	}
+
+	changes, err := sealage.Reseal(sa, before, &secrets[0], recipients)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Fprintln(os.Stderr, "no changes")
+		return nil
+	}
+
+	format := sealage.FormatYAML
+	if filepath.Ext(path) == ".json" {
+		format = sealage.FormatJSON
+	}
+	out, err := sealage.Marshal([]securityv1alpha1.SealedAge{*sa}, format)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, out, info.Mode().Perm()); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%s: %s\n", path, strings.Join(changes, ", "))
+	return nil
+}
+
+// editTemp opens content in $VISUAL or $EDITOR (default vi) and returns the
+// result. The plaintext only lives in a private temporary file.
+func editTemp(content []byte) ([]byte, error) {
+	f, err := os.CreateTemp("", "sealage-*.yaml")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	if _, err := f.Write(content); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	args := strings.Fields(editor)
+	cmd := exec.Command(args[0], append(args[1:], f.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("editor %s: %w", editor, err)
+	}
+	return os.ReadFile(f.Name())
+}
+
+// stripComments drops full-line # comments.
+func stripComments(b []byte) []byte {
+	var out [][]byte
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		if !bytes.HasPrefix(bytes.TrimSpace(line), []byte("#")) {
+			out = append(out, line)
+		}
+	}
+	return bytes.Join(out, []byte("\n"))
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+// Command sealage works with SealedAge manifests outside the cluster.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const usage = `Usage: sealage <command> [flags]
+
+Commands:
+  seal      encrypt Secret manifests into SealedAge manifests
+  validate  check SealedAge manifests offline, e.g. in CI
+  edit      decrypt a SealedAge with a local identity, edit it and reseal the changes
+
+Run "sealage <command> -h" for the flags of a command.
+`
+
+func main() {
+	if err := run(context.Background(), os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "sealage:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return flag.ErrHelp
+	}
+	switch args[0] {
+	case "seal":
+		return runSeal(ctx, args[1:], stdin, stdout)
+	case "validate":
+		return runValidate(args[1:], stdout)
+	case "edit":
+		return runEdit(ctx, args[1:])
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return nil
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// stringList is a repeatable flag.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// openInput opens path for reading; "-" is stdin.
+func openInput(path string, stdin io.Reader) (io.ReadCloser, error) {
+	if path == "-" {
+		return io.NopCloser(stdin), nil
+	}
+	return os.Open(path)
+}
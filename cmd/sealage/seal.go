@@ -0,0 +1,168 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+	"github.com/callmewhatuwant/sealed-age-operator/internal/agecrypt"
+	"github.com/callmewhatuwant/sealed-age-operator/internal/sealage"
+)
+
+func runSeal(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("seal", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: sealage seal [flags] < secret.yaml > sealedage.yaml")
+		fs.PrintDefaults()
+	}
+	var (
+		filename, namespace, output   string
+		recipientsURL, configMap      string
+		kubeconfig, kubeContext       string
+		fromCluster                   bool
+		recipientArgs, recipientFiles stringList
+	)
+	fs.StringVar(&filename, "f", "-", "Secret manifest(s) to seal, - for stdin.")
+	fs.StringVar(&filename, "filename", "-", "Secret manifest(s) to seal, - for stdin.")
+	fs.Var(&recipientArgs, "r", "Recipient to seal to (repeatable).")
+	fs.Var(&recipientArgs, "recipient", "Recipient to seal to (repeatable).")
+	fs.Var(&recipientFiles, "R", "Recipients file to seal to, as for age -R (repeatable).")
+	fs.Var(&recipientFiles, "recipients-file", "Recipients file to seal to, as for age -R (repeatable).")
+	fs.StringVar(&recipientsURL, "recipients-url", "",
+		"URL of the operator's recipients endpoint, e.g. http://localhost:8090/recipients.")
+	fs.BoolVar(&fromCluster, "from-cluster", false,
+		"Read the recipients from the operator's recipients ConfigMap in the target namespace.")
+	fs.StringVar(&configMap, "recipients-configmap", "age-recipients", "Name of the recipients ConfigMap.")
+	fs.StringVar(&kubeconfig, "kubeconfig", "", "Path to the kubeconfig file (default: $KUBECONFIG or ~/.kube/config).")
+	fs.StringVar(&kubeContext, "context", "", "Kubeconfig context to use.")
+	fs.StringVar(&namespace, "n", "", "Namespace of the SealedAges (default: the Secret's namespace).")
+	fs.StringVar(&namespace, "namespace", "", "Namespace of the SealedAges (default: the Secret's namespace).")
+	fs.StringVar(&output, "o", string(sealage.FormatYAML), "Output format: yaml or json.")
+	fs.StringVar(&output, "output", string(sealage.FormatYAML), "Output format: yaml or json.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+	format, err := sealage.ParseFormat(output)
+	if err != nil {
+		return err
+	}
+
+	in, err := openInput(filename, stdin)
+	if err != nil {
+		return err
+	}
+	secrets, err := sealage.ReadSecrets(in)
+	_ = in.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	if namespace != "" {
+		for i := range secrets {
+			secrets[i].Namespace = namespace
+		}
+	}
+
+	recipients, err := collectRecipients(ctx, recipientArgs, recipientFiles, recipientsURL)
+	if err != nil {
+		return err
+	}
+	if len(recipients) == 0 && !fromCluster {
+		return errors.New("no recipients: use -r, -R, --recipients-url or --from-cluster")
+	}
+
+	// With --from-cluster every Secret is sealed to the recipients of its own
+	// namespace, read once per namespace.
+	byNamespace := map[string][]string{}
+	sealed := make([]securityv1alpha1.SealedAge, 0, len(secrets))
+	for i := range secrets {
+		sealTo := recipients
+		if fromCluster {
+			ns := secrets[i].Namespace
+			rs, ok := byNamespace[ns]
+			if !ok {
+				if rs, err = clusterRecipients(ctx, kubeconfig, kubeContext, ns, configMap); err != nil {
+					return err
+				}
+				byNamespace[ns] = rs
+			}
+			sealTo = append(slices.Clone(recipients), rs...)
+		}
+		sa, err := sealage.Seal(&secrets[i], sealTo...)
+		if err != nil {
+			return err
+		}
+		sealed = append(sealed, *sa)
+	}
+	out, err := sealage.Marshal(sealed, format)
+	if err != nil {
+		return err
+	}
+	_, err = stdout.Write(out)
+	return err
+}
+
+// collectRecipients gathers the recipients given with -r, -R and --recipients-url.
+func collectRecipients(ctx context.Context, args, files []string, url string) ([]string, error) {
+	var recipients []string
+	for _, s := range args {
+		if _, err := agecrypt.ParseRecipient(s); err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, s)
+	}
+	for _, path := range files {
+		rs, err := sealage.RecipientsFromFile(path)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, rs...)
+	}
+	if url != "" {
+		rs, err := sealage.RecipientsFromURL(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, rs...)
+	}
+	return recipients, nil
+}
+
+// clusterRecipients reads the recipients ConfigMap from namespace, or from the
+// kubeconfig context's namespace when it is empty.
+func clusterRecipients(ctx context.Context, kubeconfig, kubeContext, namespace, name string) ([]string, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	cc := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: kubeContext})
+	if namespace == "" {
+		ns, _, err := cc.Namespace()
+		if err != nil {
+			return nil, err
+		}
+		namespace = ns
+	}
+	cfg, err := cc.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	c, err := client.New(cfg, client.Options{})
+	if err != nil {
+		return nil, err
+	}
+	return sealage.RecipientsFromConfigMap(ctx, c, types.NamespacedName{Namespace: namespace, Name: name})
+}
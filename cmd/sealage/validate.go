@@ -0,0 +1,79 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/callmewhatuwant/sealed-age-operator/internal/sealage"
+)
+
+func runValidate(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: sealage validate [flags] <dir|file>...")
+		fs.PrintDefaults()
+	}
+	var (
+		recipientsFile, output string
+		requireX25519          bool
+	)
+	fs.StringVar(&recipientsFile, "R", "", "Recipients file; every value must be sealed to one of them.")
+	fs.StringVar(&recipientsFile, "recipients-file", "", "Recipients file; every value must be sealed to one of them.")
+	fs.BoolVar(&requireX25519, "require-x25519", true, "Require an X25519 stanza on every value not using a passphraseRef.")
+	fs.StringVar(&output, "o", "text", "Output format: text or json.")
+	fs.StringVar(&output, "output", "text", "Output format: text or json.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if output != "text" && output != "json" {
+		return fmt.Errorf("unknown output format %q (text or json)", output)
+	}
+
+	v, err := sealage.NewValidator()
+	if err != nil {
+		return err
+	}
+	v.RequireX25519 = requireX25519
+	if recipientsFile != "" {
+		recipients, err := sealage.RecipientsFromFile(recipientsFile)
+		if err != nil {
+			return err
+		}
+		if v.Recipients, err = sealage.NewRecipientSet(recipients); err != nil {
+			return fmt.Errorf("%s: %w", recipientsFile, err)
+		}
+	}
+
+	rep, err := v.ValidatePaths(fs.Args()...)
+	if err != nil {
+		return err
+	}
+	if output == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(rep); err != nil {
+			return err
+		}
+	} else {
+		for _, f := range rep.Findings {
+			fmt.Fprintln(stdout, f)
+		}
+		fmt.Fprintf(stdout, "%d file(s), %d SealedAge(s): %d error(s), %d warning(s)\n",
+			rep.Files, rep.SealedAges, rep.Errors, rep.Warnings)
+	}
+	if rep.Errors > 0 {
+		return fmt.Errorf("validation failed with %d error(s)", rep.Errors)
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+// Package crd embeds the generated CustomResourceDefinitions, so tools can
+// validate manifests against the same schema the API server uses.
+package crd
+
+import _ "embed"
+
+// SealedAge is the SealedAge CRD generated into bases/ by `make manifests`.
+//
+//go:embed bases/security.age.io_sealedages.yaml
+var SealedAge []byte
@@ -0,0 +1,105 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+// Package agecrypt holds the AGE decryption logic shared by the controller
+// and the command line tooling: header parsing, key matching and decryption.
+package agecrypt
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	age "filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+const (
+	versionLine    = "age-encryption.org/v1"
+	stanzaPrefix   = "-> "
+	footerPrefix   = "---"
+	columnsPerLine = 64
+)
+
+var b64 = base64.RawStdEncoding.Strict()
+
+// IsArmored reports whether ciphertext is an ASCII-armored AGE file.
+func IsArmored(ciphertext string) bool {
+	return strings.HasPrefix(strings.TrimLeft(ciphertext, " \t\r\n"), armor.Header)
+}
+
+// Reader returns a reader over the binary AGE file, removing the armor if present.
+func Reader(ciphertext string) io.Reader {
+	if IsArmored(ciphertext) {
+		return armor.NewReader(strings.NewReader(ciphertext))
+	}
+	return strings.NewReader(ciphertext)
+}
+
+// ParseHeader returns the recipient stanzas of an (armored or binary) AGE file.
+// Only the header is read; the payload is left untouched.
+func ParseHeader(ciphertext string) ([]*age.Stanza, error) {
+	br := bufio.NewReader(Reader(ciphertext))
+
+	line, err := readLine(br)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	if line != versionLine {
+		return nil, fmt.Errorf("unsupported AGE version line %q", line)
+	}
+
+	var stanzas []*age.Stanza
+	for {
+		line, err := readLine(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read header: %w", err)
+		}
+		if strings.HasPrefix(line, footerPrefix) {
+			break
+		}
+		if !strings.HasPrefix(line, stanzaPrefix) {
+			return nil, fmt.Errorf("malformed stanza opening line %q", line)
+		}
+		args := strings.Split(strings.TrimPrefix(line, stanzaPrefix), " ")
+		if len(args) < 1 || args[0] == "" {
+			return nil, fmt.Errorf("malformed stanza %q", line)
+		}
+
+		// The body is wrapped at 64 columns and ends with a shorter (maybe empty) line.
+		var body strings.Builder
+		for {
+			l, err := readLine(br)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read stanza body: %w", err)
+			}
+			body.WriteString(l)
+			if len(l) < columnsPerLine {
+				break
+			}
+		}
+		b, err := b64.DecodeString(body.String())
+		if err != nil {
+			return nil, fmt.Errorf("malformed stanza body: %w", err)
+		}
+		stanzas = append(stanzas, &age.Stanza{Type: args[0], Args: args[1:], Body: b})
+	}
+	if len(stanzas) == 0 {
+		return nil, errors.New("no recipient stanzas in header")
+	}
+	return stanzas, nil
+}
+
+// readLine reads one LF-terminated header line without the terminator.
+func readLine(br *bufio.Reader) (string, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(line, "\n"), nil
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agecrypt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+
+	age "filippo.io/age"
+	"filippo.io/age/agessh"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/ssh"
+)
+
+// newSSHKey returns an ssh-ed25519 key as OpenSSH PEM (optionally
+// passphrase-protected) together with the matching age recipient.
+func newSSHKey(passphrase string) ([]byte, age.Recipient) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(priv, "deploy-key")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, "deploy-key", []byte(passphrase))
+	}
+	Expect(err).NotTo(HaveOccurred())
+	sshPub, err := ssh.NewPublicKey(pub)
+	Expect(err).NotTo(HaveOccurred())
+	recipient, err := agessh.NewEd25519Recipient(sshPub)
+	Expect(err).NotTo(HaveOccurred())
+	return pem.EncodeToMemory(block), recipient
+}
+
+var _ = Describe("ParseSSHIdentity", func() {
+	It("decrypts files encrypted to an ssh-ed25519 key", func() {
+		pemBytes, recipient := newSSHKey("")
+		Expect(IsSSHPrivateKey(pemBytes)).To(BeTrue())
+
+		id, err := ParseSSHIdentity(pemBytes, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		ring := &Keyring{}
+		ring.Add("age-key-x25519", newIdentity())
+		ring.Add("deploy-key", id)
+		plain, key, err := ring.Decrypt(encrypt("s3cr3t", true, recipient))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(plain)).To(Equal("s3cr3t"))
+		Expect(key.Source).To(Equal("deploy-key"))
+	})
+
+	It("decrypts with an ssh-rsa key", func() {
+		priv, err := rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).NotTo(HaveOccurred())
+		block, err := ssh.MarshalPrivateKey(priv, "")
+		Expect(err).NotTo(HaveOccurred())
+		sshPub, err := ssh.NewPublicKey(&priv.PublicKey)
+		Expect(err).NotTo(HaveOccurred())
+		recipient, err := agessh.NewRSARecipient(sshPub)
+		Expect(err).NotTo(HaveOccurred())
+
+		id, err := ParseSSHIdentity(pem.EncodeToMemory(block), nil)
+		Expect(err).NotTo(HaveOccurred())
+		ring := &Keyring{}
+		ring.Add("rsa-key", id)
+		plain, _, err := ring.Decrypt(encrypt("s3cr3t", false, recipient))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(plain)).To(Equal("s3cr3t"))
+	})
+
+	It("unlocks passphrase-protected keys", func() {
+		pemBytes, recipient := newSSHKey("hunter2")
+
+		_, err := ParseSSHIdentity(pemBytes, nil)
+		Expect(err).To(MatchError(ContainSubstring("passphrase-protected")))
+		_, err = ParseSSHIdentity(pemBytes, []byte("wrong"))
+		Expect(err).To(HaveOccurred())
+
+		id, err := ParseSSHIdentity(pemBytes, []byte("hunter2\n"))
+		Expect(err).NotTo(HaveOccurred())
+		ring := &Keyring{}
+		ring.Add("deploy-key", id)
+		plain, _, err := ring.Decrypt(encrypt("s3cr3t", true, recipient))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(plain)).To(Equal("s3cr3t"))
+	})
+
+	It("does not take X25519 identities or other PEM blocks for ssh keys", func() {
+		Expect(IsSSHPrivateKey([]byte(newIdentity().String()))).To(BeFalse())
+		Expect(IsSSHPrivateKey(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("x")}))).To(BeFalse())
+		Expect(IsSSHPrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("x")}))).To(BeFalse())
+		Expect(IsSSHPrivateKey(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: []byte("x")}))).To(BeTrue())
+	})
+})
+
+var _ = Describe("NewPassphraseIdentity", func() {
+	scrypt := func(passphrase string, workFactor int) age.Recipient {
+		r, err := age.NewScryptRecipient(passphrase)
+		Expect(err).NotTo(HaveOccurred())
+		r.SetWorkFactor(workFactor)
+		return r
+	}
+
+	It("decrypts files encrypted with a passphrase", func() {
+		id, err := NewPassphraseIdentity([]byte("correct horse\n"), 16)
+		Expect(err).NotTo(HaveOccurred())
+		ring := &Keyring{}
+		ring.Add("bootstrap", id)
+		plain, _, err := ring.Decrypt(encrypt("s3cr3t", true, scrypt("correct horse", 10)))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(plain)).To(Equal("s3cr3t"))
+	})
+
+	It("refuses work factors above the limit", func() {
+		id, err := NewPassphraseIdentity([]byte("correct horse"), 10)
+		Expect(err).NotTo(HaveOccurred())
+		ring := &Keyring{}
+		ring.Add("bootstrap", id)
+		_, _, err = ring.Decrypt(encrypt("s3cr3t", true, scrypt("correct horse", 12)))
+		Expect(err).To(MatchError(ContainSubstring("work factor")))
+	})
+
+	It("rejects an empty passphrase", func() {
+		_, err := NewPassphraseIdentity([]byte("\n"), 0)
+		Expect(err).To(HaveOccurred())
+	})
+})
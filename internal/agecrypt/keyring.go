@@ -0,0 +1,121 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+package agecrypt
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	age "filippo.io/age"
+)
+
+// ErrNoMatchingKey is returned when no key in the Keyring unwraps any stanza.
+var ErrNoMatchingKey = errors.New("no available key matches any recipient stanza")
+
+// ErrKeyRevoked is returned when a file can only be unwrapped by revoked keys.
+var ErrKeyRevoked = errors.New("file is only encrypted to revoked keys")
+
+// Key is an identity together with the name of the key Secret (or file) it came from.
+type Key struct {
+	Source   string
+	Identity age.Identity
+	// Revoked keys are never used to decrypt; they only explain why a file
+	// could not be decrypted.
+	Revoked bool
+}
+
+// Keyring is an index of identities from one or more sources.
+//
+// X25519 stanzas do not name their recipient, so matching means letting every
+// identity try to unwrap the file key from the already parsed stanzas — one
+// ECDH per identity/stanza pair — instead of a full age.Decrypt per key.
+type Keyring struct {
+	keys []Key
+}
+
+// Add appends identities loaded from source.
+func (k *Keyring) Add(source string, ids ...age.Identity) {
+	for _, id := range ids {
+		k.keys = append(k.keys, Key{Source: source, Identity: id})
+	}
+}
+
+// AddRevoked appends identities of a revoked source.
+func (k *Keyring) AddRevoked(source string, ids ...age.Identity) {
+	for _, id := range ids {
+		k.keys = append(k.keys, Key{Source: source, Identity: id, Revoked: true})
+	}
+}
+
+// Len returns the number of identities in the Keyring.
+func (k *Keyring) Len() int {
+	return len(k.keys)
+}
+
+// Match returns the first key whose identity unwraps one of the stanzas.
+// Revoked keys are only tried when no other key matches; if one of them
+// matches, it is returned together with ErrKeyRevoked.
+func (k *Keyring) Match(stanzas []*age.Stanza) (Key, error) {
+	key, _, err := k.match(stanzas)
+	return key, err
+}
+
+func (k *Keyring) match(stanzas []*age.Stanza) (Key, []byte, error) {
+	var lastErr error
+	for _, revoked := range []bool{false, true} {
+		for _, key := range k.keys {
+			if key.Revoked != revoked {
+				continue
+			}
+			fileKey, err := key.Identity.Unwrap(stanzas)
+			if err == nil {
+				if revoked {
+					return key, nil, fmt.Errorf("%w: %s", ErrKeyRevoked, key.Source)
+				}
+				return key, fileKey, nil
+			}
+			if !errors.Is(err, age.ErrIncorrectIdentity) {
+				lastErr = fmt.Errorf("%s: %w", key.Source, err)
+			}
+		}
+	}
+	if lastErr != nil {
+		return Key{}, nil, fmt.Errorf("%w (last error: %v)", ErrNoMatchingKey, lastErr)
+	}
+	return Key{}, nil, ErrNoMatchingKey
+}
+
+// Decrypt parses the header once, selects the matching key and decrypts the
+// (armored or binary) ciphertext with it. The file key unwrapped while
+// matching is reused, so plugins are only run once per file.
+func (k *Keyring) Decrypt(ciphertext string) ([]byte, Key, error) {
+	stanzas, err := ParseHeader(ciphertext)
+	if err != nil {
+		return nil, Key{}, err
+	}
+	key, fileKey, err := k.match(stanzas)
+	if err != nil {
+		return nil, key, err
+	}
+	r, err := age.Decrypt(Reader(ciphertext), unwrapped(fileKey))
+	if err != nil {
+		return nil, key, fmt.Errorf("decrypt with %s: %w", key.Source, err)
+	}
+	plain, err := io.ReadAll(r)
+	if err != nil {
+		return nil, key, fmt.Errorf("read plaintext: %w", err)
+	}
+	return plain, key, nil
+}
+
+// unwrapped is an identity for a file key that was already unwrapped.
+// age.Decrypt still verifies it against the header MAC.
+type unwrapped []byte
+
+func (u unwrapped) Unwrap([]*age.Stanza) ([]byte, error) {
+	return u, nil
+}
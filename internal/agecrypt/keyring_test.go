@@ -0,0 +1,121 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agecrypt
+
+import (
+	"bytes"
+	"io"
+
+	age "filippo.io/age"
+	"filippo.io/age/armor"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// encrypt returns plaintext encrypted to recipients, armored or binary.
+func encrypt(plaintext string, armored bool, recipients ...age.Recipient) string {
+	var buf bytes.Buffer
+	var dst io.WriteCloser = nopCloser{&buf}
+	if armored {
+		dst = armor.NewWriter(&buf)
+	}
+	w, err := age.Encrypt(dst, recipients...)
+	Expect(err).NotTo(HaveOccurred())
+	_, err = io.WriteString(w, plaintext)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(w.Close()).To(Succeed())
+	Expect(dst.Close()).To(Succeed())
+	return buf.String()
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+func newIdentity() *age.X25519Identity {
+	id, err := age.GenerateX25519Identity()
+	Expect(err).NotTo(HaveOccurred())
+	return id
+}
+
+var _ = Describe("Keyring", func() {
+	var ring *Keyring
+	var target *age.X25519Identity
+
+	BeforeEach(func() {
+		ring = &Keyring{}
+		for _, name := range []string{"age-key-1", "age-key-2", "age-key-3"} {
+			ring.Add(name, newIdentity())
+		}
+		target = newIdentity()
+		ring.Add("age-key-target", target)
+	})
+
+	DescribeTable("decrypts with the key the file is encrypted to",
+		func(armored bool) {
+			ct := encrypt("s3cr3t", armored, newIdentity().Recipient(), target.Recipient())
+
+			stanzas, err := ParseHeader(ct)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stanzas).To(HaveLen(2))
+			Expect(stanzas[0].Type).To(Equal("X25519"))
+
+			plain, key, err := ring.Decrypt(ct)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(plain)).To(Equal("s3cr3t"))
+			Expect(key.Source).To(Equal("age-key-target"))
+		},
+		Entry("armored", true),
+		Entry("binary", false),
+	)
+
+	It("reports ErrNoMatchingKey when no key matches", func() {
+		_, _, err := ring.Decrypt(encrypt("s3cr3t", true, newIdentity().Recipient()))
+		Expect(err).To(MatchError(ErrNoMatchingKey))
+	})
+
+	It("rejects input that is not an AGE file", func() {
+		_, err := ParseHeader("not age")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Keyring with revoked keys", func() {
+	It("refuses files only encrypted to a revoked key", func() {
+		revoked := newIdentity()
+		ring := &Keyring{}
+		ring.Add("age-key-active", newIdentity())
+		ring.AddRevoked("age-key-leaked", revoked)
+
+		_, key, err := ring.Decrypt(encrypt("s3cr3t", true, revoked.Recipient()))
+		Expect(err).To(MatchError(ErrKeyRevoked))
+		Expect(key.Source).To(Equal("age-key-leaked"))
+		Expect(key.Revoked).To(BeTrue())
+	})
+
+	It("prefers other keys over a revoked one", func() {
+		revoked, active := newIdentity(), newIdentity()
+		ring := &Keyring{}
+		ring.AddRevoked("age-key-leaked", revoked)
+		ring.Add("age-key-active", active)
+
+		plain, key, err := ring.Decrypt(encrypt("s3cr3t", true, revoked.Recipient(), active.Recipient()))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(plain)).To(Equal("s3cr3t"))
+		Expect(key.Source).To(Equal("age-key-active"))
+	})
+})
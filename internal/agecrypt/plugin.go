@@ -0,0 +1,439 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+package agecrypt
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	age "filippo.io/age"
+	"filippo.io/age/plugin"
+)
+
+// pluginTimeout bounds a single plugin run (see SetPluginTimeout).
+var pluginTimeout = 30 * time.Second
+
+// pluginExitGrace is how long a plugin gets to exit after it has been
+// interrupted before it is killed.
+var pluginExitGrace = time.Second
+
+// pluginDirs are searched for plugin binaries before $PATH (see
+// SetPluginPath).
+var pluginDirs []string
+
+// maxCachedUnwraps bounds the unwrap results a PluginIdentity keeps.
+const maxCachedUnwraps = 1024
+
+// PluginIdentity is an AGE-PLUGIN-<NAME>-1... identity. Unwrapping runs the
+// age-plugin-<name> binary from the plugin path or $PATH (see SetPluginPath).
+// The binary is only looked up on unwrap, so a missing plugin fails the files
+// sealed to it and not every other identity parsed next to it.
+//
+// The outcome of an unwrap is kept per file header, so trying the identity on
+// the same file again, e.g. for every field sealed with it or on every
+// reconcile, doesn't start the plugin again.
+type PluginIdentity struct {
+	id       *plugin.Identity
+	encoding string
+
+	mu        sync.Mutex
+	unwrapped map[[sha256.Size]byte][]byte
+}
+
+var _ age.Identity = &PluginIdentity{}
+
+// NewPluginIdentity parses an AGE-PLUGIN-<NAME>-1... identity.
+func NewPluginIdentity(s string) (*PluginIdentity, error) {
+	id, err := plugin.NewIdentity(s, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &PluginIdentity{id: id, encoding: s}, nil
+}
+
+// Name returns the plugin name.
+func (p *PluginIdentity) Name() string {
+	return p.id.Name()
+}
+
+// Unwrap runs the plugin, unless it already answered for these stanzas. The
+// age plugin client can't be cancelled, so the identity-v1 conversation is
+// spoken here, with the plugin process bound to the plugin timeout.
+func (p *PluginIdentity) Unwrap(stanzas []*age.Stanza) ([]byte, error) {
+	sum, err := stanzasSum(stanzas)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	fileKey, ok := p.unwrapped[sum]
+	p.mu.Unlock()
+	if ok {
+		if fileKey == nil {
+			return nil, fmt.Errorf("plugin %q: %w", p.Name(), age.ErrIncorrectIdentity)
+		}
+		return fileKey, nil
+	}
+
+	err = runPlugin(p.Name(), "identity-v1", func(w io.Writer, r *bufio.Reader) error {
+		fileKey, err = p.unwrap(w, r, stanzas)
+		return err
+	})
+	// Only a definitive answer is kept: a plugin that failed or timed out is
+	// asked again next time.
+	if err == nil || errors.Is(err, age.ErrIncorrectIdentity) {
+		p.mu.Lock()
+		if p.unwrapped == nil || len(p.unwrapped) >= maxCachedUnwraps {
+			p.unwrapped = make(map[[sha256.Size]byte][]byte)
+		}
+		p.unwrapped[sum] = fileKey
+		p.mu.Unlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fileKey, nil
+}
+
+// stanzasSum identifies a file header by its recipient stanzas.
+func stanzasSum(stanzas []*age.Stanza) ([sha256.Size]byte, error) {
+	h := sha256.New()
+	for _, s := range stanzas {
+		if err := writeStanza(h, s); err != nil {
+			return [sha256.Size]byte{}, err
+		}
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+// unwrap speaks the identity-v1 protocol: the identity and the recipient
+// stanzas go to the plugin, which answers with the file key, if any. There is
+// nobody to prompt, so messages, requests and confirmations fail.
+func (p *PluginIdentity) unwrap(w io.Writer, r *bufio.Reader, stanzas []*age.Stanza) ([]byte, error) {
+	// Phase 1: the identity and the stanzas of file 0.
+	if err := writeStanza(w, &age.Stanza{Type: "add-identity", Args: []string{p.encoding}}); err != nil {
+		return nil, err
+	}
+	for _, s := range stanzas {
+		rs := &age.Stanza{
+			Type: "recipient-stanza",
+			Args: append([]string{"0", s.Type}, s.Args...),
+			Body: s.Body,
+		}
+		if err := writeStanza(w, rs); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeStanza(w, &age.Stanza{Type: "done"}); err != nil {
+		return nil, err
+	}
+
+	// Phase 2: the plugin's commands, up to "done".
+	var fileKey []byte
+	for {
+		s, err := readStanza(r)
+		if err != nil {
+			return nil, err
+		}
+		switch s.Type {
+		case "file-key":
+			if len(s.Args) != 1 || s.Args[0] != "0" {
+				return nil, fmt.Errorf("malformed file-key stanza")
+			}
+			if fileKey != nil {
+				return nil, errors.New("received duplicated file-key stanza")
+			}
+			fileKey = s.Body
+			err = writeStanza(w, &age.Stanza{Type: "ok"})
+		case "error":
+			if err := writeStanza(w, &age.Stanza{Type: "ok"}); err != nil {
+				return nil, err
+			}
+			return nil, errors.New(string(s.Body))
+		case "msg", "request-secret", "request-public", "confirm":
+			err = writeStanza(w, &age.Stanza{Type: "fail"})
+		case "done":
+			if fileKey == nil {
+				return nil, age.ErrIncorrectIdentity
+			}
+			return fileKey, nil
+		default:
+			err = writeStanza(w, &age.Stanza{Type: "unsupported"})
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// runPlugin runs age-plugin-<name> for the given protocol and has talk speak
+// it over the plugin's stdin and stdout. A plugin that doesn't finish within
+// the plugin timeout is killed, and runPlugin only returns once the process
+// has exited.
+func runPlugin(name, protocol string, talk func(w io.Writer, r *bufio.Reader) error) error {
+	path, err := lookPlugin(name)
+	if err != nil {
+		return fmt.Errorf("plugin %q: %w", name, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pluginTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path, "--age-plugin="+protocol)
+	// Plugins must not rely on the working directory, as in the age client.
+	cmd.Dir = os.TempDir()
+	// Don't wait on children of the plugin that hold on to its pipes.
+	cmd.WaitDelay = time.Second
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("plugin %q: %w", name, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("plugin %q: %w", name, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("plugin %q: %w", name, err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- talk(stdin, bufio.NewReader(stdout))
+	}()
+	select {
+	case err := <-done:
+		// Like the age client: close stdin and interrupt the plugin so it
+		// can clean up, but kill it if it doesn't exit soon after.
+		_ = stdin.Close()
+		_ = cmd.Process.Signal(os.Interrupt)
+		kill := time.AfterFunc(pluginExitGrace, cancel)
+		_ = cmd.Wait()
+		kill.Stop()
+		if err != nil {
+			return fmt.Errorf("plugin %q: %w", name, err)
+		}
+		return nil
+	case <-ctx.Done():
+		// The context killed the process; Wait reaps it and closes its
+		// pipes, which ends the conversation.
+		_ = cmd.Wait()
+		<-done
+		return fmt.Errorf("plugin %q: %w", name, ctx.Err())
+	}
+}
+
+// lookPlugin finds the age-plugin-<name> binary, first in the plugin path,
+// then on $PATH.
+func lookPlugin(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return "", errors.New("invalid plugin name")
+	}
+	bin := "age-plugin-" + name
+	for _, dir := range pluginDirs {
+		if path, err := exec.LookPath(filepath.Join(dir, bin)); err == nil {
+			return path, nil
+		}
+	}
+	return exec.LookPath(bin)
+}
+
+// PluginRecipient is an age1<name>1... recipient. Wrapping runs the
+// age-plugin-<name> binary like PluginIdentity does, bound to the plugin
+// timeout.
+type PluginRecipient struct {
+	name     string
+	encoding string
+}
+
+var _ age.RecipientWithLabels = &PluginRecipient{}
+
+// NewPluginRecipient parses an age1<name>1... recipient.
+func NewPluginRecipient(s string) (*PluginRecipient, error) {
+	r, err := plugin.NewRecipient(s, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &PluginRecipient{name: r.Name(), encoding: s}, nil
+}
+
+// Name returns the plugin name.
+func (p *PluginRecipient) Name() string {
+	return p.name
+}
+
+// Wrap runs the plugin to wrap fileKey.
+func (p *PluginRecipient) Wrap(fileKey []byte) ([]*age.Stanza, error) {
+	stanzas, _, err := p.WrapWithLabels(fileKey)
+	return stanzas, err
+}
+
+// WrapWithLabels runs the plugin to wrap fileKey and also returns the labels
+// the plugin reports for the recipient.
+func (p *PluginRecipient) WrapWithLabels(fileKey []byte) ([]*age.Stanza, []string, error) {
+	var stanzas []*age.Stanza
+	var labels []string
+	err := runPlugin(p.name, "recipient-v1", func(w io.Writer, r *bufio.Reader) error {
+		var err error
+		stanzas, labels, err = p.wrap(w, r, fileKey)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return stanzas, labels, nil
+}
+
+// wrap speaks the recipient-v1 protocol: the recipient and the file key go to
+// the plugin, which answers with the recipient stanzas. As in unwrap, requests
+// for user interaction fail.
+func (p *PluginRecipient) wrap(w io.Writer, r *bufio.Reader, fileKey []byte) ([]*age.Stanza, []string, error) {
+	// Phase 1: the recipient, the file key and a request for labels.
+	for _, s := range []*age.Stanza{
+		{Type: "add-recipient", Args: []string{p.encoding}},
+		{Type: "wrap-file-key", Body: fileKey},
+		{Type: "extension-labels"},
+		{Type: "done"},
+	} {
+		if err := writeStanza(w, s); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Phase 2: the plugin's commands, up to "done".
+	var stanzas []*age.Stanza
+	var labels []string
+	for {
+		s, err := readStanza(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch s.Type {
+		case "recipient-stanza":
+			if len(s.Args) < 2 || s.Args[0] != "0" {
+				return nil, nil, errors.New("malformed recipient-stanza stanza")
+			}
+			stanzas = append(stanzas, &age.Stanza{Type: s.Args[1], Args: s.Args[2:], Body: s.Body})
+			err = writeStanza(w, &age.Stanza{Type: "ok"})
+		case "labels":
+			if labels != nil {
+				return nil, nil, errors.New("received duplicated labels stanza")
+			}
+			labels = s.Args
+			err = writeStanza(w, &age.Stanza{Type: "ok"})
+		case "error":
+			if err := writeStanza(w, &age.Stanza{Type: "ok"}); err != nil {
+				return nil, nil, err
+			}
+			return nil, nil, errors.New(string(s.Body))
+		case "msg", "request-secret", "request-public", "confirm":
+			err = writeStanza(w, &age.Stanza{Type: "fail"})
+		case "done":
+			if len(stanzas) == 0 {
+				return nil, nil, errors.New("received zero recipient stanzas")
+			}
+			return stanzas, labels, nil
+		default:
+			err = writeStanza(w, &age.Stanza{Type: "unsupported"})
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// stanzaColumns is the width of the base64 body lines of a stanza.
+const stanzaColumns = 64
+
+// writeStanza writes s in the age stanza encoding: an "-> type args..."
+// line, then the unpadded base64 body wrapped at 64 columns and ended by a
+// shorter, possibly empty, line.
+func writeStanza(w io.Writer, s *age.Stanza) error {
+	var b strings.Builder
+	b.WriteString("-> " + strings.Join(append([]string{s.Type}, s.Args...), " ") + "\n")
+	enc := base64.RawStdEncoding.EncodeToString(s.Body)
+	for len(enc) >= stanzaColumns {
+		b.WriteString(enc[:stanzaColumns] + "\n")
+		enc = enc[stanzaColumns:]
+	}
+	b.WriteString(enc + "\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// readStanza reads a stanza written by writeStanza.
+func readStanza(r *bufio.Reader) (*age.Stanza, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	args := strings.Split(strings.TrimSuffix(line, "\n"), " ")
+	if len(args) < 2 || args[0] != "->" || args[1] == "" {
+		return nil, fmt.Errorf("malformed stanza %q", line)
+	}
+	s := &age.Stanza{Type: args[1], Args: args[2:]}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSuffix(line, "\n")
+		b, err := base64.RawStdEncoding.DecodeString(line)
+		if err != nil || len(line) > stanzaColumns {
+			return nil, fmt.Errorf("malformed stanza body %q", line)
+		}
+		s.Body = append(s.Body, b...)
+		if len(line) < stanzaColumns {
+			return s, nil
+		}
+	}
+}
+
+// SetPluginTimeout sets how long a plugin may take to wrap or unwrap a file
+// (default 30s). Call it once at startup.
+func SetPluginTimeout(d time.Duration) error {
+	if d <= 0 {
+		return errors.New("the plugin timeout must be positive")
+	}
+	pluginTimeout = d
+	return nil
+}
+
+// SetPluginPath sets the directories searched for age-plugin-* binaries
+// before $PATH, so plugins found there win over the system ones. $PATH itself
+// is left alone. Call it once at startup, before any plugin is used.
+func SetPluginPath(dirs ...string) error {
+	var abs []string
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		d, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(d)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return errors.New(d + " is not a directory")
+		}
+		abs = append(abs, d)
+	}
+	pluginDirs = abs
+	return nil
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agecrypt
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	age "filippo.io/age"
+	"filippo.io/age/plugin"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakeRecipient wraps the file key into a "fake" stanza that
+// testdata/age-plugin-fake unwraps again.
+type fakeRecipient struct{}
+
+func (fakeRecipient) Wrap(fileKey []byte) ([]*age.Stanza, error) {
+	return []*age.Stanza{{Type: "fake", Body: fileKey}}, nil
+}
+
+var _ = Describe("Plugin identities", Ordered, func() {
+	BeforeAll(func() {
+		dir := GinkgoT().TempDir()
+		build := exec.Command("go", "build", "-o", filepath.Join(dir, "age-plugin-fake"), "./testdata/age-plugin-fake")
+		out, err := build.CombinedOutput()
+		Expect(err).NotTo(HaveOccurred(), string(out))
+
+		path := os.Getenv("PATH")
+		Expect(SetPluginPath(dir)).To(Succeed())
+		DeferCleanup(SetPluginPath)
+		Expect(os.Getenv("PATH")).To(Equal(path))
+	})
+
+	It("decrypts through the age-plugin binary", func() {
+		identity := plugin.EncodeIdentity("fake", []byte("slot-1"))
+		ids, err := ParseIdentities(strings.NewReader("# plugin key\n" + identity + "\n" + newIdentity().String() + "\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids).To(HaveLen(2))
+
+		ring := &Keyring{}
+		ring.Add("age-key-plugin", ids...)
+		plain, key, err := ring.Decrypt(encrypt("from plugin", true, fakeRecipient{}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(plain)).To(Equal("from plugin"))
+		Expect(key.Identity).To(BeAssignableToTypeOf(&PluginIdentity{}))
+	})
+
+	It("encrypts to plugin recipients through the age-plugin binary", func() {
+		rcpt, err := ParseRecipient(plugin.EncodeRecipient("fake", []byte("slot-1")))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rcpt).To(BeAssignableToTypeOf(&PluginRecipient{}))
+		ciphertext, err := Encrypt([]byte("to plugin"), rcpt)
+		Expect(err).NotTo(HaveOccurred())
+
+		id, err := NewPluginIdentity(plugin.EncodeIdentity("fake", []byte("slot-1")))
+		Expect(err).NotTo(HaveOccurred())
+		ring := &Keyring{}
+		ring.Add("age-key-plugin", id)
+		plain, _, err := ring.Decrypt(ciphertext)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(plain)).To(Equal("to plugin"))
+	})
+
+	It("runs the plugin once per file", func() {
+		runs := filepath.Join(GinkgoT().TempDir(), "runs")
+		GinkgoT().Setenv("AGE_PLUGIN_FAKE_RUNS", runs)
+
+		id, err := NewPluginIdentity(plugin.EncodeIdentity("fake", []byte("slot-1")))
+		Expect(err).NotTo(HaveOccurred())
+		ring := &Keyring{}
+		ring.Add("age-key-plugin", id)
+		matching := encrypt("from plugin", true, fakeRecipient{})
+		other := encrypt("s3cr3t", true, newIdentity().Recipient())
+		for range 3 {
+			plain, _, err := ring.Decrypt(matching)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(plain)).To(Equal("from plugin"))
+			_, _, err = ring.Decrypt(other)
+			Expect(err).To(MatchError(ErrNoMatchingKey))
+		}
+
+		out, err := os.ReadFile(runs)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(strings.Count(string(out), "identity-v1")).To(Equal(2))
+	})
+
+	It("does not match files the plugin cannot unwrap", func() {
+		id, err := NewPluginIdentity(plugin.EncodeIdentity("fake", []byte("slot-1")))
+		Expect(err).NotTo(HaveOccurred())
+		ring := &Keyring{}
+		ring.Add("age-key-plugin", id)
+		_, _, err = ring.Decrypt(encrypt("s3cr3t", true, newIdentity().Recipient()))
+		Expect(err).To(MatchError(ErrNoMatchingKey))
+	})
+
+	It("only fails files sealed to a plugin without a binary", func() {
+		x25519 := newIdentity()
+		ids, err := ParseIdentities(strings.NewReader(plugin.EncodeIdentity("missing", []byte("x")) + "\n" + x25519.String() + "\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids).To(HaveLen(2))
+
+		ring := &Keyring{}
+		ring.Add("age-key-plugin", ids...)
+		plain, _, err := ring.Decrypt(encrypt("still readable", true, x25519.Recipient()))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(plain)).To(Equal("still readable"))
+
+		_, err = ids[0].Unwrap([]*age.Stanza{{Type: "missing"}})
+		Expect(err).To(MatchError(ContainSubstring(`plugin "missing"`)))
+	})
+
+	It("kills plugins that don't answer in time", func() {
+		pidFile := filepath.Join(GinkgoT().TempDir(), "pid")
+		GinkgoT().Setenv("AGE_PLUGIN_FAKE_HANG", "1")
+		GinkgoT().Setenv("AGE_PLUGIN_FAKE_PIDFILE", pidFile)
+		Expect(SetPluginTimeout(100 * time.Millisecond)).To(Succeed())
+		DeferCleanup(SetPluginTimeout, 30*time.Second)
+
+		id, err := NewPluginIdentity(plugin.EncodeIdentity("fake", []byte("slot-1")))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = id.Unwrap([]*age.Stanza{{Type: "fake", Body: make([]byte, 16)}})
+		Expect(err).To(MatchError(context.DeadlineExceeded))
+
+		// Unwrap has reaped the plugin, so its process is gone.
+		pid, err := os.ReadFile(pidFile)
+		Expect(err).NotTo(HaveOccurred())
+		n, err := strconv.Atoi(string(pid))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(syscall.Kill(n, 0)).To(MatchError(syscall.ESRCH))
+	})
+
+	It("kills plugins that ignore the interrupt once done", func() {
+		pidFile := filepath.Join(GinkgoT().TempDir(), "pid")
+		GinkgoT().Setenv("AGE_PLUGIN_FAKE_LINGER", "1")
+		GinkgoT().Setenv("AGE_PLUGIN_FAKE_PIDFILE", pidFile)
+
+		id, err := NewPluginIdentity(plugin.EncodeIdentity("fake", []byte("slot-1")))
+		Expect(err).NotTo(HaveOccurred())
+		start := time.Now()
+		fileKey, err := id.Unwrap([]*age.Stanza{{Type: "fake", Body: []byte("file key")}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(fileKey)).To(Equal("file key"))
+		Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+
+		pid, err := os.ReadFile(pidFile)
+		Expect(err).NotTo(HaveOccurred())
+		n, err := strconv.Atoi(string(pid))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(syscall.Kill(n, 0)).To(MatchError(syscall.ESRCH))
+	})
+})
@@ -0,0 +1,83 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+package agecrypt
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	age "filippo.io/age"
+	"filippo.io/age/agessh"
+	"filippo.io/age/armor"
+)
+
+// ParseRecipient parses a single recipient: a native age1... X25519
+// recipient, an ssh-ed25519/ssh-rsa public key or a plugin recipient
+// (age1<name>1...), the same set `age -r` accepts.
+func ParseRecipient(s string) (age.Recipient, error) {
+	switch {
+	case strings.HasPrefix(s, "ssh-"):
+		return agessh.ParseRecipient(s)
+	case strings.HasPrefix(s, "age1"):
+		if r, err := age.ParseX25519Recipient(s); err == nil {
+			return r, nil
+		}
+		return NewPluginRecipient(s)
+	default:
+		return nil, fmt.Errorf("unknown recipient type %q", s)
+	}
+}
+
+// ParseRecipients parses a recipients file as read by `age -R`: one recipient
+// per line, blank lines and # comments ignored.
+func ParseRecipients(r io.Reader) ([]age.Recipient, error) {
+	var recipients []age.Recipient
+	scanner := bufio.NewScanner(r)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rcpt, err := ParseRecipient(line)
+		if err != nil {
+			return nil, fmt.Errorf("error at line %d: %w", n, err)
+		}
+		recipients = append(recipients, rcpt)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recipients: %w", err)
+	}
+	if len(recipients) == 0 {
+		return nil, errors.New("no recipients found")
+	}
+	return recipients, nil
+}
+
+// Encrypt seals plaintext to recipients and returns it as an armored AGE file.
+func Encrypt(plaintext []byte, recipients ...age.Recipient) (string, error) {
+	var buf bytes.Buffer
+	aw := armor.NewWriter(&buf)
+	w, err := age.Encrypt(aw, recipients...)
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	if err := aw.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package agecrypt
+
+import (
+	"strings"
+
+	age "filippo.io/age"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/ssh"
+)
+
+var _ = Describe("Recipients", func() {
+	It("parses a recipients file and encrypts to every recipient", func() {
+		x25519 := newIdentity()
+		pemBytes, _ := newSSHKey("")
+		sshID, err := ParseSSHIdentity(pemBytes, nil)
+		Expect(err).NotTo(HaveOccurred())
+		signer, err := ssh.ParsePrivateKey(pemBytes)
+		Expect(err).NotTo(HaveOccurred())
+
+		file := strings.Join([]string{
+			"# team keys",
+			x25519.Recipient().String(),
+			"",
+			strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey()))),
+		}, "\n")
+		recipients, err := ParseRecipients(strings.NewReader(file))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recipients).To(HaveLen(2))
+
+		armored, err := Encrypt([]byte("s3cr3t"), recipients...)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(IsArmored(armored)).To(BeTrue())
+		for _, id := range []age.Identity{x25519, sshID} {
+			ring := &Keyring{}
+			ring.Add("key", id)
+			plain, _, err := ring.Decrypt(armored)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(plain)).To(Equal("s3cr3t"))
+		}
+	})
+
+	It("reports the offending line", func() {
+		_, err := ParseRecipients(strings.NewReader("age1notarecipient\nfoo"))
+		Expect(err).To(MatchError(ContainSubstring("error at line 1")))
+		_, err = ParseRecipients(strings.NewReader("# nothing here\n"))
+		Expect(err).To(MatchError("no recipients found"))
+	})
+})
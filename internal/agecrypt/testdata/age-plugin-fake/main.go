@@ -0,0 +1,168 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+// Command age-plugin-fake is a minimal age plugin for the agecrypt tests.
+// For any AGE-PLUGIN-FAKE-1... identity it unwraps "fake" recipient stanzas,
+// whose body is the plain file key, and for any age1fake1... recipient it
+// wraps the file key into one, so no real plugin or hardware is needed.
+// With AGE_PLUGIN_FAKE_HANG set it stalls like a plugin waiting on hardware,
+// with AGE_PLUGIN_FAKE_LINGER set it ignores the interrupt it gets when done
+// and stays around, AGE_PLUGIN_FAKE_PIDFILE names a file it writes its
+// process ID to and AGE_PLUGIN_FAKE_RUNS one it appends a line to per run.
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const columnsPerLine = 64
+
+func main() {
+	var run func(*bufio.Reader, io.Writer) error
+	switch {
+	case len(os.Args) == 2 && os.Args[1] == "--age-plugin=identity-v1":
+		run = unwrap
+	case len(os.Args) == 2 && os.Args[1] == "--age-plugin=recipient-v1":
+		run = wrap
+	default:
+		fmt.Fprintln(os.Stderr, "usage: age-plugin-fake --age-plugin=identity-v1|recipient-v1")
+		os.Exit(1)
+	}
+	if f := os.Getenv("AGE_PLUGIN_FAKE_PIDFILE"); f != "" {
+		if err := os.WriteFile(f, []byte(strconv.Itoa(os.Getpid())), 0o600); err != nil {
+			fail(err)
+		}
+	}
+	if f := os.Getenv("AGE_PLUGIN_FAKE_RUNS"); f != "" {
+		runs, err := os.OpenFile(f, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err == nil {
+			_, err = fmt.Fprintln(runs, os.Args[1])
+			_ = runs.Close()
+		}
+		if err != nil {
+			fail(err)
+		}
+	}
+	if os.Getenv("AGE_PLUGIN_FAKE_LINGER") != "" {
+		signal.Ignore(os.Interrupt)
+	}
+	if os.Getenv("AGE_PLUGIN_FAKE_HANG") != "" {
+		time.Sleep(10 * time.Second)
+	}
+	if err := run(bufio.NewReader(os.Stdin), os.Stdout); err != nil {
+		fail(err)
+	}
+	if os.Getenv("AGE_PLUGIN_FAKE_LINGER") != "" {
+		time.Sleep(10 * time.Second)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "age-plugin-fake:", err)
+	os.Exit(1)
+}
+
+func unwrap(in *bufio.Reader, out io.Writer) error {
+	// Phase 1: identities and recipient stanzas, up to "done".
+	var fileKey []byte
+	for {
+		args, body, err := readStanza(in)
+		if err != nil {
+			return err
+		}
+		if args[0] == "done" {
+			break
+		}
+		// recipient-stanza <file index> <stanza type> [args...]
+		if args[0] == "recipient-stanza" && len(args) >= 3 && args[2] == "fake" && fileKey == nil {
+			fileKey = body
+		}
+	}
+
+	// Phase 2: hand back the file key, if any, and finish.
+	if fileKey != nil {
+		if err := writeStanza(out, []string{"file-key", "0"}, fileKey); err != nil {
+			return err
+		}
+		if _, _, err := readStanza(in); err != nil {
+			return err
+		}
+	}
+	return writeStanza(out, []string{"done"}, nil)
+}
+
+func wrap(in *bufio.Reader, out io.Writer) error {
+	// Phase 1: recipients and the file key, up to "done".
+	var fileKey []byte
+	for {
+		args, body, err := readStanza(in)
+		if err != nil {
+			return err
+		}
+		if args[0] == "done" {
+			break
+		}
+		if args[0] == "wrap-file-key" {
+			fileKey = body
+		}
+	}
+
+	// Phase 2: hand back the "fake" stanza and finish.
+	if err := writeStanza(out, []string{"recipient-stanza", "0", "fake"}, fileKey); err != nil {
+		return err
+	}
+	if _, _, err := readStanza(in); err != nil {
+		return err
+	}
+	return writeStanza(out, []string{"done"}, nil)
+}
+
+func readStanza(in *bufio.Reader) ([]string, []byte, error) {
+	line, err := in.ReadString('\n')
+	if err != nil {
+		return nil, nil, err
+	}
+	args := strings.Fields(strings.TrimPrefix(strings.TrimSuffix(line, "\n"), "-> "))
+	if !strings.HasPrefix(line, "-> ") || len(args) == 0 {
+		return nil, nil, fmt.Errorf("malformed stanza %q", line)
+	}
+	var body []byte
+	for {
+		line, err := in.ReadString('\n')
+		if err != nil {
+			return nil, nil, err
+		}
+		line = strings.TrimSuffix(line, "\n")
+		b, err := base64.RawStdEncoding.DecodeString(line)
+		if err != nil {
+			return nil, nil, err
+		}
+		body = append(body, b...)
+		if len(line) < columnsPerLine {
+			return args, body, nil
+		}
+	}
+}
+
+func writeStanza(out io.Writer, args []string, body []byte) error {
+	enc := base64.RawStdEncoding.EncodeToString(body)
+	var b strings.Builder
+	b.WriteString("-> " + strings.Join(args, " ") + "\n")
+	for len(enc) >= columnsPerLine {
+		b.WriteString(enc[:columnsPerLine] + "\n")
+		enc = enc[columnsPerLine:]
+	}
+	b.WriteString(enc + "\n")
+	_, err := io.WriteString(out, b.String())
+	return err
+}
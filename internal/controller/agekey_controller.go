@@ -0,0 +1,293 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	age "filippo.io/age"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+)
+
+// AgeKeyReconciler reconciles AgeKey resources. An AgeKey manages the key
+// Secret of the same name: a missing Secret is generated once, an unowned key
+// Secret is adopted. spec.state is written to the Secret's lifecycle annotations.
+type AgeKeyReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// Configurable via CLI flags (see cmd/main.go)
+	KeyNamespace string // default: "sealed-age-system"
+	KeyLabelKey  string // default: "app"
+	KeyLabelVal  string // default: "age-key"
+
+	// Recorder emits Kubernetes Events on AgeKeys (optional).
+	Recorder record.EventRecorder
+
+	// Keys caches parsed key identities; shared with the SealedAgeReconciler.
+	// SetupWithManager creates one when unset.
+	Keys *KeyStore
+
+	now clock
+}
+
+// +kubebuilder:rbac:groups=security.age.io,resources=agekeys,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=security.age.io,resources=agekeys/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=security.age.io,resources=agekeys/finalizers,verbs=update
+
+func (r *AgeKeyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithValues("agekey", req.NamespacedName)
+
+	var ak securityv1alpha1.AgeKey
+	if err := r.Get(ctx, req.NamespacedName, &ak); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !ak.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	if ak.Namespace != r.KeyNamespace {
+		r.setReady(&ak, metav1.ConditionFalse, securityv1alpha1.ReasonKeyInvalid,
+			fmt.Sprintf("AgeKeys must be created in the key namespace %s", r.KeyNamespace))
+		return ctrl.Result{}, r.updateStatus(ctx, &ak)
+	}
+
+	// 1. Find, generate or adopt the key Secret.
+	var secret corev1.Secret
+	reason := securityv1alpha1.ReasonSucceeded
+	err := r.Get(ctx, req.NamespacedName, &secret)
+	switch {
+	case apierrors.IsNotFound(err) && ak.Status.Recipient != "":
+		// Never replace a key that existed: data sealed to it would be lost for good.
+		msg := fmt.Sprintf("key Secret %s was deleted, restore it or delete the AgeKey", ak.Name)
+		recordEvent(r.Recorder, &ak, corev1.EventTypeWarning, securityv1alpha1.ReasonSecretMissing, "%s", msg)
+		r.setReady(&ak, metav1.ConditionFalse, securityv1alpha1.ReasonSecretMissing, msg)
+		return ctrl.Result{}, r.updateStatus(ctx, &ak)
+	case apierrors.IsNotFound(err):
+		gen, gerr := generateKeySecret(ak.Namespace, ak.Name, map[string]string{
+			securityv1alpha1.ManagedByLabel: securityv1alpha1.ManagedByValue,
+		}, r.now.Now())
+		if gerr != nil {
+			return ctrl.Result{}, gerr
+		}
+		secret = *gen
+		reason = securityv1alpha1.ReasonKeyGenerated
+	case err != nil:
+		return ctrl.Result{}, err
+	case secret.Annotations[securityv1alpha1.AgeKeyAnnotation] != ak.Name:
+		if owner := metav1.GetControllerOf(&secret); owner != nil {
+			msg := fmt.Sprintf("key Secret %s is controlled by %s %s", secret.Name, owner.Kind, owner.Name)
+			r.setReady(&ak, metav1.ConditionFalse, securityv1alpha1.ReasonSecretConflict, msg)
+			return ctrl.Result{}, r.updateStatus(ctx, &ak)
+		}
+		// Only adopt Secrets that are keys already, not whatever has the name.
+		if secret.Labels[r.KeyLabelKey] != r.KeyLabelVal {
+			msg := fmt.Sprintf("Secret %s is not a key Secret, label it %s=%s to adopt it",
+				secret.Name, r.KeyLabelKey, r.KeyLabelVal)
+			recordEvent(r.Recorder, &ak, corev1.EventTypeWarning, securityv1alpha1.ReasonSecretConflict, "%s", msg)
+			r.setReady(&ak, metav1.ConditionFalse, securityv1alpha1.ReasonSecretConflict, msg)
+			return ctrl.Result{}, r.updateStatus(ctx, &ak)
+		}
+		reason = securityv1alpha1.ReasonKeyAdopted
+	}
+
+	// 2. Write the AgeKey link, key label and lifecycle state to the Secret.
+	// The Secret gets no owner reference, generated or adopted: deleting the
+	// AgeKey (or its CRD) must never garbage collect a key, data sealed to it
+	// would be lost. Only retiring and pruning delete keys.
+	existing := secret.DeepCopy()
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[securityv1alpha1.AgeKeyAnnotation] = ak.Name
+	applyKeyState(&secret, r.KeyLabelKey, r.KeyLabelVal, stateOf(&ak))
+	switch {
+	case reason == securityv1alpha1.ReasonKeyGenerated:
+		if err := r.Create(ctx, &secret); err != nil {
+			return ctrl.Result{}, fmt.Errorf("create key secret %s: %w", secret.Name, err)
+		}
+		logger.Info("generated key", "secret", secret.Name)
+		recordEvent(r.Recorder, &ak, corev1.EventTypeNormal, securityv1alpha1.ReasonKeyGenerated,
+			"generated key Secret %s", secret.Name)
+	case !equality.Semantic.DeepEqual(existing, &secret):
+		if err := r.Update(ctx, &secret); err != nil {
+			return ctrl.Result{}, fmt.Errorf("update key secret %s: %w", secret.Name, err)
+		}
+		if reason == securityv1alpha1.ReasonKeyAdopted {
+			logger.Info("adopted key", "secret", secret.Name)
+			recordEvent(r.Recorder, &ak, corev1.EventTypeNormal, securityv1alpha1.ReasonKeyAdopted,
+				"adopted key Secret %s", secret.Name)
+		}
+	}
+
+	// 3. Report recipient and usage.
+	ak.Status.SecretName = secret.Name
+	ak.Status.State = stateOf(&ak)
+	if !secret.CreationTimestamp.IsZero() {
+		ak.Status.CreatedAt = secret.CreationTimestamp.DeepCopy()
+	}
+	ids, err := r.identities(&secret)
+	if err != nil {
+		r.setReady(&ak, metav1.ConditionFalse, securityv1alpha1.ReasonKeyInvalid, err.Error())
+		return ctrl.Result{}, r.updateStatus(ctx, &ak)
+	}
+	ak.Status.Recipient = recipientOf(&secret, ids)
+
+	var list securityv1alpha1.SealedAgeList
+	if err := r.List(ctx, &list); err != nil {
+		return ctrl.Result{}, err
+	}
+	refs, err := referencingSealedAges(parseSealedHeaders(list.Items), ids)
+	if err != nil {
+		// The count is a lower bound then; the key is still usable.
+		logger.V(1).Info("could not check every SealedAge against the key", "err", err.Error())
+	}
+	ak.Status.SealedAges = int32(len(refs))
+
+	if reason == securityv1alpha1.ReasonSucceeded {
+		reason = readyReason(&ak)
+	}
+	r.setReady(&ak, metav1.ConditionTrue, reason, fmt.Sprintf("key Secret %s is %s", secret.Name, ak.Status.State))
+	return ctrl.Result{}, r.updateStatus(ctx, &ak)
+}
+
+// identities parses the key Secret through the shared KeyStore, so AgeKeys read
+// the same --key-fields as the SealedAge controller.
+func (r *AgeKeyReconciler) identities(secret *corev1.Secret) ([]age.Identity, error) {
+	if secret.UID == "" {
+		return parseKeySecret(secret, r.Keys.fields)
+	}
+	e := r.Keys.get(secret)
+	return e.identities, e.err
+}
+
+// stateOf returns spec.state, defaulting to active for objects the API server did not default.
+func stateOf(ak *securityv1alpha1.AgeKey) securityv1alpha1.KeyState {
+	if ak.Spec.State == "" {
+		return securityv1alpha1.KeyStateActive
+	}
+	return ak.Spec.State
+}
+
+// applyKeyState labels the Secret as a key and writes its lifecycle state,
+// including the legacy "active" annotation read by older tooling.
+func applyKeyState(secret *corev1.Secret, labelKey, labelVal string, state securityv1alpha1.KeyState) {
+	if secret.Labels == nil {
+		secret.Labels = map[string]string{}
+	}
+	secret.Labels[labelKey] = labelVal
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[securityv1alpha1.KeyStateAnnotation] = string(state)
+	secret.Annotations[securityv1alpha1.LegacyActiveAnnotation] = fmt.Sprint(state == securityv1alpha1.KeyStateActive)
+}
+
+// recipientOf derives the recipient from the first X25519 identity, falling
+// back to the Secret's "public" field (e.g. for plugin identities).
+func recipientOf(secret *corev1.Secret, ids []age.Identity) string {
+	for _, id := range ids {
+		if x, ok := id.(*age.X25519Identity); ok {
+			return x.Recipient().String()
+		}
+	}
+	return string(secret.Data[keyPublicField])
+}
+
+// readyReason keeps KeyGenerated/KeyAdopted as the Ready reason once set, so
+// later reconciles don't hide how the key came to be.
+func readyReason(ak *securityv1alpha1.AgeKey) string {
+	if c := meta.FindStatusCondition(ak.Status.Conditions, securityv1alpha1.ConditionReady); c != nil &&
+		(c.Reason == securityv1alpha1.ReasonKeyGenerated || c.Reason == securityv1alpha1.ReasonKeyAdopted) {
+		return c.Reason
+	}
+	return securityv1alpha1.ReasonSucceeded
+}
+
+func (r *AgeKeyReconciler) setReady(ak *securityv1alpha1.AgeKey, status metav1.ConditionStatus, reason, msg string) {
+	meta.SetStatusCondition(&ak.Status.Conditions, metav1.Condition{
+		Type:               securityv1alpha1.ConditionReady,
+		Status:             status,
+		Reason:             reason,
+		Message:            msg,
+		ObservedGeneration: ak.Generation,
+	})
+}
+
+// updateStatus writes ak.Status back, ignoring AgeKeys deleted in the meantime.
+func (r *AgeKeyReconciler) updateStatus(ctx context.Context, ak *securityv1alpha1.AgeKey) error {
+	ak.Status.ObservedGeneration = ak.Generation
+	return client.IgnoreNotFound(r.Status().Update(ctx, ak))
+}
+
+// AgeKeysInstalled reports whether the API server serves the AgeKey CRD. The
+// AgeKey controller and the AgeKeys of rotated keys need it; without it key
+// Secrets are managed on their own.
+func AgeKeysInstalled(mapper meta.RESTMapper) (bool, error) {
+	gk := schema.GroupKind{Group: securityv1alpha1.GroupVersion.Group, Kind: "AgeKey"}
+	_, err := mapper.RESTMapping(gk, securityv1alpha1.GroupVersion.Version)
+	if meta.IsNoMatchError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *AgeKeyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Keys == nil {
+		r.Keys = NewKeyStore()
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&securityv1alpha1.AgeKey{}).
+		// Generated and adopted key Secrets alike: they share the AgeKey's name.
+		Watches(&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.ageKeyForSecret),
+			builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+				return obj.GetNamespace() == r.KeyNamespace
+			})),
+		).
+		Watches(&securityv1alpha1.SealedAge{},
+			handler.EnqueueRequestsFromMapFunc(r.allAgeKeys),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		).
+		Complete(r)
+}
+
+// ageKeyForSecret enqueues the AgeKey named like a key namespace Secret.
+func (r *AgeKeyReconciler) ageKeyForSecret(_ context.Context, obj client.Object) []reconcile.Request {
+	return []reconcile.Request{{NamespacedName: client.ObjectKeyFromObject(obj)}}
+}
+
+// allAgeKeys enqueues every AgeKey when a SealedAge's spec changes, so usage
+// counts follow resealed and deleted SealedAges.
+func (r *AgeKeyReconciler) allAgeKeys(ctx context.Context, _ client.Object) []reconcile.Request {
+	var list securityv1alpha1.AgeKeyList
+	if err := r.List(ctx, &list, client.InNamespace(r.KeyNamespace)); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list agekeys for sealedage change")
+		return nil
+	}
+	reqs := make([]reconcile.Request, 0, len(list.Items))
+	for i := range list.Items {
+		reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&list.Items[i])})
+	}
+	return reqs
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+)
+
+var _ = Describe("AgeKey Controller", func() {
+	ctx := context.Background()
+
+	var r *AgeKeyReconciler
+
+	reconcileKey := func(name string) *securityv1alpha1.AgeKey {
+		nn := types.NamespacedName{Name: name, Namespace: testKeyNamespace}
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: nn})
+		Expect(err).NotTo(HaveOccurred())
+		var ak securityv1alpha1.AgeKey
+		Expect(k8sClient.Get(ctx, nn, &ak)).To(Succeed())
+		return &ak
+	}
+
+	createAgeKey := func(name string, state securityv1alpha1.KeyState) *securityv1alpha1.AgeKey {
+		ak := &securityv1alpha1.AgeKey{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testKeyNamespace},
+			Spec:       securityv1alpha1.AgeKeySpec{State: state},
+		}
+		Expect(k8sClient.Create(ctx, ak)).To(Succeed())
+		return ak
+	}
+
+	BeforeEach(func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testKeyNamespace}}
+		if err := k8sClient.Create(ctx, ns); err != nil && !errors.IsAlreadyExists(err) {
+			Expect(err).NotTo(HaveOccurred())
+		}
+		r = &AgeKeyReconciler{
+			Client:       k8sClient,
+			Scheme:       k8sClient.Scheme(),
+			KeyNamespace: testKeyNamespace,
+			KeyLabelKey:  testKeyLabelKey,
+			KeyLabelVal:  testKeyLabelVal,
+			Keys:         NewKeyStore(),
+		}
+		DeferCleanup(func() {
+			Expect(k8sClient.DeleteAllOf(ctx, &securityv1alpha1.AgeKey{}, client.InNamespace(testKeyNamespace))).To(Succeed())
+			Expect(k8sClient.DeleteAllOf(ctx, &corev1.Secret{}, client.InNamespace(testKeyNamespace),
+				client.MatchingLabels{testKeyLabelKey: testKeyLabelVal})).To(Succeed())
+		})
+	})
+
+	It("generates a key Secret and never regenerates it", func() {
+		createAgeKey("generated-key", securityv1alpha1.KeyStateActive)
+		ak := reconcileKey("generated-key")
+
+		var secret corev1.Secret
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "generated-key", Namespace: testKeyNamespace}, &secret)).To(Succeed())
+		Expect(secret.OwnerReferences).To(BeEmpty(), "deleting the AgeKey must not delete the key")
+		Expect(secret.Annotations).To(HaveKeyWithValue(securityv1alpha1.AgeKeyAnnotation, "generated-key"))
+		Expect(secret.Labels).To(HaveKeyWithValue(testKeyLabelKey, testKeyLabelVal))
+		Expect(keyState(&secret)).To(Equal(securityv1alpha1.KeyStateActive))
+		ids, err := parseKeySecret(&secret, []string{DefaultKeyField})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids).To(HaveLen(1))
+
+		Expect(ak.Status.Recipient).To(HavePrefix("age1"))
+		Expect(ak.Status.Recipient).To(Equal(string(secret.Data["public"])))
+		Expect(ak.Status.SecretName).To(Equal("generated-key"))
+		Expect(ak.Status.State).To(Equal(securityv1alpha1.KeyStateActive))
+		ready := meta.FindStatusCondition(ak.Status.Conditions, securityv1alpha1.ConditionReady)
+		Expect(ready).NotTo(BeNil())
+		Expect(ready.Status).To(Equal(metav1.ConditionTrue))
+		Expect(ready.Reason).To(Equal(securityv1alpha1.ReasonKeyGenerated))
+
+		By("reporting a deleted Secret instead of generating a new key")
+		Expect(k8sClient.Delete(ctx, &secret)).To(Succeed())
+		ak = reconcileKey("generated-key")
+		ready = meta.FindStatusCondition(ak.Status.Conditions, securityv1alpha1.ConditionReady)
+		Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+		Expect(ready.Reason).To(Equal(securityv1alpha1.ReasonSecretMissing))
+		err = k8sClient.Get(ctx, types.NamespacedName{Name: "generated-key", Namespace: testKeyNamespace}, &secret)
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("adopts an existing key Secret, syncs its state and counts SealedAges using it", func() {
+		secret, id := newKeySecret("adopted-key")
+		Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+		other := newIdentity()
+
+		sealedTo := func(name string, data map[string]string) {
+			cr := &securityv1alpha1.SealedAge{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+				Spec:       securityv1alpha1.SealedAgeSpec{EncryptedData: data},
+			}
+			Expect(k8sClient.Create(ctx, cr)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, cr)).To(Succeed()) })
+		}
+		sealedTo("uses-key", map[string]string{
+			"a": encryptArmored("a", other.Recipient()),
+			"b": encryptArmored("b", other.Recipient(), id.Recipient()),
+		})
+		sealedTo("uses-key-twice", map[string]string{
+			"a": encryptArmored("a", id.Recipient()),
+			"b": encryptArmored("b", id.Recipient()),
+		})
+		sealedTo("uses-other-key", map[string]string{"a": encryptArmored("a", other.Recipient())})
+
+		createAgeKey("adopted-key", securityv1alpha1.KeyStateRetired)
+		ak := reconcileKey("adopted-key")
+		Expect(ak.Status.Recipient).To(Equal(id.Recipient().String()))
+		Expect(ak.Status.State).To(Equal(securityv1alpha1.KeyStateRetired))
+		Expect(ak.Status.SealedAges).To(Equal(int32(2)))
+		Expect(meta.FindStatusCondition(ak.Status.Conditions, securityv1alpha1.ConditionReady).Reason).
+			To(Equal(securityv1alpha1.ReasonKeyAdopted))
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(secret), secret)).To(Succeed())
+		Expect(secret.OwnerReferences).To(BeEmpty(), "deleting the AgeKey must not delete an adopted key")
+		Expect(secret.Annotations).To(HaveKeyWithValue(securityv1alpha1.AgeKeyAnnotation, "adopted-key"))
+		Expect(secret.Annotations).To(HaveKeyWithValue(securityv1alpha1.KeyStateAnnotation, "retired"))
+		Expect(secret.Annotations).To(HaveKeyWithValue(securityv1alpha1.LegacyActiveAnnotation, "false"))
+		Expect(keyState(secret)).To(Equal(securityv1alpha1.KeyStateRetired))
+
+		By("revoking through the AgeKey")
+		ak.Spec.State = securityv1alpha1.KeyStateRevoked
+		Expect(k8sClient.Update(ctx, ak)).To(Succeed())
+		ak = reconcileKey("adopted-key")
+		Expect(ak.Status.State).To(Equal(securityv1alpha1.KeyStateRevoked))
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(secret), secret)).To(Succeed())
+		Expect(keyState(secret)).To(Equal(securityv1alpha1.KeyStateRevoked))
+	})
+
+	It("refuses to adopt a Secret that isn't a key", func() {
+		secret, _ := newKeySecret("not-a-key")
+		secret.Labels = nil
+		Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+		DeferCleanup(func() { Expect(k8sClient.Delete(ctx, secret)).To(Succeed()) })
+
+		createAgeKey("not-a-key", securityv1alpha1.KeyStateActive)
+		ak := reconcileKey("not-a-key")
+		ready := meta.FindStatusCondition(ak.Status.Conditions, securityv1alpha1.ConditionReady)
+		Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+		Expect(ready.Reason).To(Equal(securityv1alpha1.ReasonSecretConflict))
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(secret), secret)).To(Succeed())
+		Expect(secret.Labels).NotTo(HaveKey(testKeyLabelKey))
+		Expect(secret.Annotations).NotTo(HaveKey(securityv1alpha1.AgeKeyAnnotation))
+	})
+
+	It("refuses AgeKeys outside the key namespace", func() {
+		ak := &securityv1alpha1.AgeKey{ObjectMeta: metav1.ObjectMeta{Name: "misplaced-key", Namespace: "default"}}
+		Expect(k8sClient.Create(ctx, ak)).To(Succeed())
+		DeferCleanup(func() { Expect(k8sClient.Delete(ctx, ak)).To(Succeed()) })
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ak)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(ak), ak)).To(Succeed())
+		Expect(meta.FindStatusCondition(ak.Status.Conditions, securityv1alpha1.ConditionReady).Reason).
+			To(Equal(securityv1alpha1.ReasonKeyInvalid))
+		var secret corev1.Secret
+		err = k8sClient.Get(ctx, client.ObjectKeyFromObject(ak), &secret)
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("detects whether the AgeKey CRD is installed", func() {
+		installed, err := AgeKeysInstalled(k8sClient.RESTMapper())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(installed).To(BeTrue())
+
+		installed, err = AgeKeysInstalled(meta.NewDefaultRESTMapper(nil))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(installed).To(BeFalse())
+	})
+})
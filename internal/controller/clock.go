@@ -0,0 +1,20 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+package controller
+
+import "time"
+
+// clock returns the current time. Tests set it to move time forward; the zero
+// value is the wall clock.
+type clock func() time.Time
+
+// Now returns the time of c, or time.Now when c is nil.
+func (c clock) Now() time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	return c()
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+package controller
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+)
+
+// stageConditions are the reconcile stages in the order they run.
+// A failing stage leaves every later stage Unknown.
+var stageConditions = []string{
+	securityv1alpha1.ConditionKeysAvailable,
+	securityv1alpha1.ConditionDecrypted,
+	securityv1alpha1.ConditionSecretSynced,
+}
+
+// setCondition sets (or replaces) a condition on the SealedAge status.
+func setCondition(cr *securityv1alpha1.SealedAge, condType string, status metav1.ConditionStatus, reason, msg string) {
+	meta.SetStatusCondition(&cr.Status.Conditions, metav1.Condition{
+		Type:               condType,
+		Status:             status,
+		Reason:             reason,
+		Message:            msg,
+		ObservedGeneration: cr.Generation,
+	})
+}
+
+// markFailed records a failing stage: the stage itself becomes False, all
+// later stages Unknown, and Ready False with the same reason.
+func markFailed(cr *securityv1alpha1.SealedAge, condType, reason, msg string) {
+	failed := false
+	for _, t := range stageConditions {
+		switch {
+		case t == condType:
+			setCondition(cr, t, metav1.ConditionFalse, reason, msg)
+			failed = true
+		case failed:
+			setCondition(cr, t, metav1.ConditionUnknown, reason, "blocked by "+condType)
+		}
+	}
+	setCondition(cr, securityv1alpha1.ConditionReady, metav1.ConditionFalse, reason, msg)
+}
+
+// markReady flips Ready to True once every stage succeeded.
+func markReady(cr *securityv1alpha1.SealedAge, msg string) {
+	setCondition(cr, securityv1alpha1.ConditionReady, metav1.ConditionTrue, securityv1alpha1.ReasonSucceeded, msg)
+}
+
+// setKeysCurrent records the key Secrets used for decryption in the status and
+// flags SealedAges that still depend on retired keys.
+func setKeysCurrent(cr *securityv1alpha1.SealedAge, used map[string]bool, keySecrets []corev1.Secret) {
+	cr.Status.KeySecrets = nil
+	for name := range used {
+		cr.Status.KeySecrets = append(cr.Status.KeySecrets, name)
+	}
+	sort.Strings(cr.Status.KeySecrets)
+
+	var retired []string
+	for i := range keySecrets {
+		if used[keySecrets[i].Name] && keyState(&keySecrets[i]) == securityv1alpha1.KeyStateRetired {
+			retired = append(retired, keySecrets[i].Name)
+		}
+	}
+	if len(retired) == 0 {
+		setCondition(cr, securityv1alpha1.ConditionKeysCurrent, metav1.ConditionTrue, securityv1alpha1.ReasonSucceeded,
+			"all fields are sealed to active keys")
+		return
+	}
+	sort.Strings(retired)
+	setCondition(cr, securityv1alpha1.ConditionKeysCurrent, metav1.ConditionFalse, securityv1alpha1.ReasonRetiredKeyInUse,
+		"fields are sealed to retired key secret(s) "+strings.Join(retired, ", ")+", reseal them")
+}
+
+// updateStatus writes cr.Status back — ignore NotFound, keep logs clean.
+func (r *SealedAgeReconciler) updateStatus(ctx context.Context, cr *securityv1alpha1.SealedAge) {
+	cr.Status.ObservedGeneration = cr.Generation
+	if err := r.Status().Update(ctx, cr); err != nil {
+		if apierrors.IsNotFound(err) {
+			// CR was deleted before status update — ignore silently.
+			return
+		}
+		log.FromContext(ctx).V(1).Info("non-fatal: failed to update status", "error", err)
+	}
+}
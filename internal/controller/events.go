@@ -0,0 +1,49 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+package controller
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+// Event reasons emitted by the SealedAge controller. Failure events reuse the
+// matching condition reasons from api/v1alpha1.
+const (
+	EventReasonDecrypted       = "Decrypted"
+	EventReasonWaitingForKeys  = "WaitingForKeys"
+	EventReasonSecretCreated   = "SecretCreated"
+	EventReasonSecretUpdated   = "SecretUpdated"
+	EventReasonSecretRecreated = "SecretRecreated"
+	EventReasonSecretDeleted   = "SecretDeleted"
+	EventReasonSecretAdopted   = "SecretAdopted"
+	EventReasonSecretOrphaned  = "SecretOrphaned"
+	EventReasonResealed        = "Resealed"
+)
+
+// Event reasons emitted on key Secrets by the KeyRotator and KeyPruner.
+const (
+	EventReasonKeyRotated  = "KeyRotated"
+	EventReasonKeyRetired  = "KeyRetired"
+	EventReasonKeyDeleted  = "KeyDeleted"
+	EventReasonKeyPrunable = "KeyPrunable"
+)
+
+// Event reasons emitted on a key Secret whose deletion is blocked: SealedAges
+// are sealed to it, or that could not be checked.
+const (
+	EventReasonKeyInUse       = "KeyInUse"
+	EventReasonKeyCheckFailed = "KeyCheckFailed"
+)
+
+// recordEvent records a Kubernetes Event on obj; a no-op when rec is nil, as
+// the Recorder of every controller and runnable is optional.
+func recordEvent(rec record.EventRecorder, obj runtime.Object, eventType, reason, messageFmt string, args ...interface{}) {
+	if rec == nil {
+		return
+	}
+	rec.Eventf(obj, eventType, reason, messageFmt, args...)
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+)
+
+// retainSecret reports whether the generated Secret must survive the SealedAge.
+func retainSecret(cr *securityv1alpha1.SealedAge) bool {
+	return cr.Annotations[securityv1alpha1.RetainSecretAnnotation] == "true" ||
+		cr.Spec.DeletionPolicy == securityv1alpha1.DeletionPolicyOrphan
+}
+
+// finalize applies the deletion policy to the generated Secret and releases
+// the finalizer. With Delete the owner reference lets the garbage collector
+// remove the Secret; with Orphan (or the retain annotation) the owner
+// reference is stripped first so the Secret stays.
+func (r *SealedAgeReconciler) finalize(ctx context.Context, cr *securityv1alpha1.SealedAge) error {
+	logger := log.FromContext(ctx)
+	if !controllerutil.ContainsFinalizer(cr, securityv1alpha1.SecretFinalizer) {
+		return nil
+	}
+
+	if retainSecret(cr) {
+		name := cr.Status.SecretName
+		if name == "" {
+			name = secretNameFor(cr)
+		}
+		if err := r.orphanSecret(ctx, cr, name); err != nil {
+			return err
+		}
+		logger.Info("orphaned secret", "secret", name)
+		recordEvent(r.Recorder, cr, corev1.EventTypeNormal, EventReasonSecretOrphaned, "kept Secret %s after deletion", name)
+	}
+
+	controllerutil.RemoveFinalizer(cr, securityv1alpha1.SecretFinalizer)
+	return client.IgnoreNotFound(r.Update(ctx, cr))
+}
+
+// orphanSecret removes cr's owner reference from the named Secret.
+func (r *SealedAgeReconciler) orphanSecret(ctx context.Context, cr *securityv1alpha1.SealedAge, name string) error {
+	var secret corev1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: cr.Namespace}, &secret); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	refs := secret.OwnerReferences[:0]
+	for _, ref := range secret.OwnerReferences {
+		if ref.UID != cr.UID {
+			refs = append(refs, ref)
+		}
+	}
+	if len(refs) == len(secret.OwnerReferences) {
+		return nil
+	}
+	secret.OwnerReferences = refs
+	return r.Update(ctx, &secret)
+}
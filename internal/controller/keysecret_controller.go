@@ -0,0 +1,170 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+)
+
+// maxBlockersListed caps the SealedAges named in a KeyInUse event.
+const maxBlockersListed = 10
+
+// KeySecretReconciler protects key Secrets with the KeyFinalizer: a key
+// Secret that is deleted while SealedAge ciphertext is still sealed to it
+// stays (and keeps decrypting) until the last of those SealedAges is
+// resealed or deleted.
+type KeySecretReconciler struct {
+	client.Client
+
+	// Configurable via CLI flags (see cmd/main.go)
+	KeyNamespace string // default: "sealed-age-system"
+	KeyLabelKey  string // default: "app"
+	KeyLabelVal  string // default: "age-key"
+
+	// Disabled removes the finalizer from every key Secret instead of adding it.
+	Disabled bool
+
+	// Recorder emits Kubernetes Events on key Secrets (optional).
+	Recorder record.EventRecorder
+
+	// Keys caches parsed key identities; shared with the other controllers.
+	// SetupWithManager creates one when unset.
+	Keys *KeyStore
+}
+
+func (r *KeySecretReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithValues("keySecret", req.NamespacedName)
+
+	var secret corev1.Secret
+	if err := r.Get(ctx, req.NamespacedName, &secret); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// No longer a key (label removed) or protection disabled: let it go.
+	if r.Disabled || !r.isKeySecret(&secret) {
+		return ctrl.Result{}, r.release(ctx, &secret)
+	}
+
+	if secret.DeletionTimestamp.IsZero() {
+		if controllerutil.AddFinalizer(&secret, securityv1alpha1.KeyFinalizer) {
+			if err := r.Update(ctx, &secret); err != nil {
+				return ctrl.Result{}, client.IgnoreNotFound(err)
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+	if !controllerutil.ContainsFinalizer(&secret, securityv1alpha1.KeyFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	// A key that can't be checked may still be needed, e.g. a typo in the data
+	// or a missing plugin: hold it and retry with backoff until it can be.
+	e := r.Keys.get(&secret)
+	if e.err != nil {
+		recordEvent(r.Recorder, &secret, corev1.EventTypeWarning, EventReasonKeyCheckFailed,
+			"deletion blocked, the key can't be parsed: %v", e.err)
+		return ctrl.Result{}, fmt.Errorf("parse key secret: %w", e.err)
+	}
+	var list securityv1alpha1.SealedAgeList
+	if err := r.List(ctx, &list); err != nil {
+		return ctrl.Result{}, err
+	}
+	blockers, err := referencingSealedAges(parseSealedHeaders(list.Items), e.identities)
+	if err != nil {
+		recordEvent(r.Recorder, &secret, corev1.EventTypeWarning, EventReasonKeyCheckFailed,
+			"deletion blocked, SealedAges could not be checked against this key: %v", err)
+		return ctrl.Result{}, err
+	}
+	if len(blockers) > 0 {
+		// The SealedAge watch requeues us once they are resealed or deleted.
+		logger.Info("key secret deletion blocked", "sealedAges", len(blockers))
+		recordEvent(r.Recorder, &secret, corev1.EventTypeWarning, EventReasonKeyInUse,
+			"deletion blocked, %d SealedAge(s) are still sealed to this key: %s", len(blockers), listBlockers(blockers))
+		return ctrl.Result{}, nil
+	}
+	logger.Info("key secret no longer in use, releasing it")
+	return ctrl.Result{}, r.release(ctx, &secret)
+}
+
+// release removes the KeyFinalizer, if present.
+func (r *KeySecretReconciler) release(ctx context.Context, secret *corev1.Secret) error {
+	if !controllerutil.RemoveFinalizer(secret, securityv1alpha1.KeyFinalizer) {
+		return nil
+	}
+	return client.IgnoreNotFound(r.Update(ctx, secret))
+}
+
+// listBlockers formats the first maxBlockersListed SealedAges.
+func listBlockers(blockers []types.NamespacedName) string {
+	var names []string
+	for i, nn := range blockers {
+		if i == maxBlockersListed {
+			names = append(names, fmt.Sprintf("and %d more", len(blockers)-i))
+			break
+		}
+		names = append(names, nn.String())
+	}
+	return strings.Join(names, ", ")
+}
+
+// isKeySecret matches AGE key Secrets (key namespace + key label).
+func (r *KeySecretReconciler) isKeySecret(obj client.Object) bool {
+	return obj.GetNamespace() == r.KeyNamespace && obj.GetLabels()[r.KeyLabelKey] == r.KeyLabelVal
+}
+
+func (r *KeySecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Keys == nil {
+		r.Keys = NewKeyStore()
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("keysecret").
+		// Key Secrets, plus former ones that still carry the finalizer.
+		For(&corev1.Secret{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+			return r.isKeySecret(obj) || controllerutil.ContainsFinalizer(obj, securityv1alpha1.KeyFinalizer)
+		}))).
+		Watches(&securityv1alpha1.SealedAge{},
+			handler.EnqueueRequestsFromMapFunc(r.deletingKeySecrets),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		).
+		Complete(r)
+}
+
+// deletingKeySecrets enqueues the key Secrets waiting for their finalizer
+// when a SealedAge changes or goes away.
+func (r *KeySecretReconciler) deletingKeySecrets(ctx context.Context, _ client.Object) []reconcile.Request {
+	var list corev1.SecretList
+	if err := r.List(ctx, &list,
+		client.InNamespace(r.KeyNamespace),
+		client.MatchingLabels{r.KeyLabelKey: r.KeyLabelVal},
+	); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list key secrets for sealedage change")
+		return nil
+	}
+	var reqs []reconcile.Request
+	for i := range list.Items {
+		if !list.Items[i].DeletionTimestamp.IsZero() {
+			reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&list.Items[i])})
+		}
+	}
+	return reqs
+}
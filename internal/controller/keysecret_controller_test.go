@@ -0,0 +1,142 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	"filippo.io/age/plugin"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+)
+
+var _ = Describe("KeySecret Controller", func() {
+	ctx := context.Background()
+
+	var r *KeySecretReconciler
+
+	reconcileSecret := func(s *corev1.Secret) {
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(s)})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testKeyNamespace}}
+		if err := k8sClient.Create(ctx, ns); err != nil && !errors.IsAlreadyExists(err) {
+			Expect(err).NotTo(HaveOccurred())
+		}
+		r = &KeySecretReconciler{
+			Client:       k8sClient,
+			KeyNamespace: testKeyNamespace,
+			KeyLabelKey:  testKeyLabelKey,
+			KeyLabelVal:  testKeyLabelVal,
+			Recorder:     record.NewFakeRecorder(32),
+			Keys:         NewKeyStore(),
+		}
+	})
+
+	It("blocks deleting a key Secret until no SealedAge is sealed to it", func() {
+		key, id := newKeySecret("age-key-in-use")
+		Expect(k8sClient.Create(ctx, key)).To(Succeed())
+		reconcileSecret(key)
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(key), key)).To(Succeed())
+		Expect(key.Finalizers).To(ContainElement(securityv1alpha1.KeyFinalizer))
+
+		cr := &securityv1alpha1.SealedAge{
+			ObjectMeta: metav1.ObjectMeta{Name: "sealed-to-key-in-use", Namespace: "default"},
+			Spec: securityv1alpha1.SealedAgeSpec{EncryptedData: map[string]string{
+				"password": encryptArmored("s3cr3t", newIdentity().Recipient(), id.Recipient()),
+			}},
+		}
+		Expect(k8sClient.Create(ctx, cr)).To(Succeed())
+
+		By("deleting the key while the SealedAge is sealed to it")
+		Expect(k8sClient.Delete(ctx, key)).To(Succeed())
+		reconcileSecret(key)
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(key), key)).To(Succeed())
+		Expect(key.DeletionTimestamp.IsZero()).To(BeFalse())
+		Expect(<-r.Recorder.(*record.FakeRecorder).Events).To(
+			ContainSubstring("KeyInUse deletion blocked, 1 SealedAge(s) are still sealed to this key: default/sealed-to-key-in-use"))
+		Expect(r.deletingKeySecrets(ctx, cr)).To(ContainElement(
+			reconcile.Request{NamespacedName: client.ObjectKeyFromObject(key)}))
+
+		By("releasing it once the SealedAge is gone")
+		Expect(k8sClient.Delete(ctx, cr)).To(Succeed())
+		reconcileSecret(key)
+		err := k8sClient.Get(ctx, client.ObjectKeyFromObject(key), key)
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("keeps the finalizer on keys it can't check", func() {
+		broken, _ := newKeySecret("age-key-broken")
+		broken.Data["private"] = []byte("AGE-SECRET-KEY-1TYPO")
+		plugged, _ := newKeySecret("age-key-plugin")
+		plugged.Data["private"] = []byte(plugin.EncodeIdentity("missing", []byte("slot-1")))
+		cr := &securityv1alpha1.SealedAge{
+			ObjectMeta: metav1.ObjectMeta{Name: "sealed-to-someone", Namespace: "default"},
+			Spec: securityv1alpha1.SealedAgeSpec{EncryptedData: map[string]string{
+				"password": encryptArmored("s3cr3t", newIdentity().Recipient()),
+			}},
+		}
+		Expect(k8sClient.Create(ctx, cr)).To(Succeed())
+		DeferCleanup(func() { Expect(k8sClient.Delete(ctx, cr)).To(Succeed()) })
+
+		for _, key := range []*corev1.Secret{broken, plugged} {
+			Expect(k8sClient.Create(ctx, key)).To(Succeed())
+			reconcileSecret(key)
+			Expect(k8sClient.Delete(ctx, key)).To(Succeed())
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(key)})
+			Expect(err).To(HaveOccurred())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(key), key)).To(Succeed(), key.Name)
+			Expect(<-r.Recorder.(*record.FakeRecorder).Events).To(ContainSubstring("Warning KeyCheckFailed deletion blocked"))
+
+			controllerutil.RemoveFinalizer(key, securityv1alpha1.KeyFinalizer)
+			Expect(k8sClient.Update(ctx, key)).To(Succeed())
+		}
+	})
+
+	It("removes the finalizer when protection is disabled or the label is gone", func() {
+		key, _ := newKeySecret("age-key-unprotected")
+		controllerutil.AddFinalizer(key, securityv1alpha1.KeyFinalizer)
+		Expect(k8sClient.Create(ctx, key)).To(Succeed())
+		DeferCleanup(func() { Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, key))).To(Succeed()) })
+
+		r.Disabled = true
+		reconcileSecret(key)
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(key), key)).To(Succeed())
+		Expect(key.Finalizers).To(BeEmpty())
+
+		r.Disabled = false
+		reconcileSecret(key)
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(key), key)).To(Succeed())
+		Expect(key.Finalizers).To(ContainElement(securityv1alpha1.KeyFinalizer))
+		delete(key.Labels, testKeyLabelKey)
+		Expect(k8sClient.Update(ctx, key)).To(Succeed())
+		reconcileSecret(key)
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(key), key)).To(Succeed())
+		Expect(key.Finalizers).To(BeEmpty())
+	})
+})
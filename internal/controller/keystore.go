@@ -0,0 +1,289 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	age "filippo.io/age"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+	"github.com/callmewhatuwant/sealed-age-operator/internal/agecrypt"
+)
+
+// keyEntry is the parsed form of one key Secret at one resourceVersion.
+type keyEntry struct {
+	name            string
+	resourceVersion string
+	identities      []age.Identity
+	err             error
+}
+
+// KeyStore caches the parsed identities of key Secrets across reconciles,
+// keyed by Secret UID and invalidated when the resourceVersion changes.
+// An informer handler (see keyStoreHandler) keeps it warm and evicts deleted
+// keys; reconciles still pass the current key list so they never see stale keys.
+type KeyStore struct {
+	mu      sync.RWMutex
+	entries map[types.UID]keyEntry
+	fields  []string
+}
+
+// NewKeyStore returns an empty KeyStore that reads identities from the given
+// key Secret data fields (default: "private").
+func NewKeyStore(fields ...string) *KeyStore {
+	if len(fields) == 0 {
+		fields = []string{DefaultKeyField}
+	}
+	return &KeyStore{entries: map[types.UID]keyEntry{}, fields: fields}
+}
+
+// Keyring returns the identities of the given key Secrets, parsing only
+// Secrets that are new or changed since they were last seen. Active keys come
+// before retired ones, so files sealed to both are attributed to the active key.
+func (s *KeyStore) Keyring(ctx context.Context, keySecrets []corev1.Secret) *agecrypt.Keyring {
+	logger := log.FromContext(ctx)
+	ring := &agecrypt.Keyring{}
+	var retired []keyEntry
+	for i := range keySecrets {
+		e := s.get(&keySecrets[i])
+		if e.err != nil {
+			logger.V(1).Info("skipping key secret", "secret", e.name, "err", e.err)
+			continue
+		}
+		switch keyState(&keySecrets[i]) {
+		case securityv1alpha1.KeyStateRevoked:
+			ring.AddRevoked(e.name, e.identities...)
+		case securityv1alpha1.KeyStateRetired:
+			retired = append(retired, e)
+		default:
+			ring.Add(e.name, e.identities...)
+		}
+	}
+	for _, e := range retired {
+		ring.Add(e.name, e.identities...)
+	}
+	return ring
+}
+
+// Upsert parses secret unless the cached entry is already at its resourceVersion.
+func (s *KeyStore) Upsert(secret *corev1.Secret) {
+	s.get(secret)
+}
+
+// Delete evicts the entry of a deleted key Secret.
+func (s *KeyStore) Delete(uid types.UID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, uid)
+	keyStoreEntries.Set(float64(len(s.entries)))
+}
+
+// Len returns the number of cached key Secrets.
+func (s *KeyStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.entries)
+}
+
+func (s *KeyStore) get(secret *corev1.Secret) keyEntry {
+	s.mu.RLock()
+	e, ok := s.entries[secret.UID]
+	s.mu.RUnlock()
+	if ok && e.resourceVersion == secret.ResourceVersion && e.name == secret.Name {
+		return e
+	}
+
+	ids, err := parseKeySecret(secret, s.fields)
+	e = keyEntry{
+		name:            secret.Name,
+		resourceVersion: secret.ResourceVersion,
+		identities:      ids,
+		err:             err,
+	}
+	s.mu.Lock()
+	s.entries[secret.UID] = e
+	keyStoreEntries.Set(float64(len(s.entries)))
+	s.mu.Unlock()
+	keyStoreRefreshes.Inc()
+	return e
+}
+
+// keyState returns the lifecycle state of a key Secret. Unknown values of the
+// key-state annotation count as revoked, so a typo never keeps a leaked key in use.
+func keyState(secret *corev1.Secret) securityv1alpha1.KeyState {
+	if v, ok := secret.Annotations[securityv1alpha1.KeyStateAnnotation]; ok {
+		switch state := securityv1alpha1.KeyState(strings.ToLower(strings.TrimSpace(v))); state {
+		case securityv1alpha1.KeyStateActive, securityv1alpha1.KeyStateRetired, securityv1alpha1.KeyStateRevoked:
+			return state
+		default:
+			return securityv1alpha1.KeyStateRevoked
+		}
+	}
+	if strings.EqualFold(secret.Annotations[securityv1alpha1.LegacyActiveAnnotation], "false") {
+		return securityv1alpha1.KeyStateRetired
+	}
+	return securityv1alpha1.KeyStateActive
+}
+
+// DefaultKeyField is the key Secret data field holding the private identities.
+const DefaultKeyField = "private"
+
+// Data fields of a key Secret besides the private key itself.
+const (
+	keyTypeField       = "type"
+	keyPassphraseField = "passphrase"
+	keyPublicField     = "public"
+)
+
+// parseKeySecret parses the identities stored in the given fields of a key
+// Secret. Missing fields are skipped, but at least one has to be present.
+func parseKeySecret(secret *corev1.Secret, fields []string) ([]age.Identity, error) {
+	var ids []age.Identity
+	found := false
+	for _, field := range fields {
+		data, ok := secret.Data[field]
+		if !ok {
+			continue
+		}
+		found = true
+		fieldIDs, err := parseKeyField(secret, data)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", field, err)
+		}
+		ids = append(ids, fieldIDs...)
+	}
+	if !found {
+		return nil, fmt.Errorf("missing %s field in key secret", quoteFields(fields))
+	}
+	return ids, nil
+}
+
+// parseKeyField parses one field of a key Secret. The key type comes from the
+// "type" field, the key-type annotation or, if neither is set, from the content.
+//
+// X25519 fields use the age identity file format: one AGE-SECRET-KEY-1 (or
+// AGE-PLUGIN-...) identity per line, blank lines and # comments allowed, as
+// written by age-keygen.
+func parseKeyField(secret *corev1.Secret, data []byte) ([]age.Identity, error) {
+	keyType := secret.Annotations[securityv1alpha1.KeyTypeAnnotation]
+	if t, ok := secret.Data[keyTypeField]; ok {
+		keyType = strings.TrimSpace(string(t))
+	}
+	if keyType == "" && agecrypt.IsSSHPrivateKey(data) {
+		keyType = securityv1alpha1.KeyTypeSSH
+	}
+
+	switch strings.ToLower(keyType) {
+	case "", securityv1alpha1.KeyTypeX25519:
+		ids, err := agecrypt.ParseIdentities(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private identities: %w", err)
+		}
+		return ids, nil
+	case securityv1alpha1.KeyTypeSSH:
+		id, err := agecrypt.ParseSSHIdentity(data, secret.Data[keyPassphraseField])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ssh identity: %w", err)
+		}
+		return []age.Identity{id}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", keyType)
+	}
+}
+
+func quoteFields(fields []string) string {
+	quoted := make([]string, len(fields))
+	for i, f := range fields {
+		quoted[i] = "'" + f + "'"
+	}
+	return strings.Join(quoted, "/")
+}
+
+// generateKeySecret returns a key Secret holding a fresh X25519 identity,
+// stored like age-keygen output, with its recipient in the "public" field.
+func generateKeySecret(namespace, name string, labels map[string]string, now time.Time) (*corev1.Secret, error) {
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		return nil, err
+	}
+	recipient := id.Recipient().String()
+	private := fmt.Sprintf("# created: %s\n# public key: %s\n%s\n", now.UTC().Format(time.RFC3339), recipient, id)
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+			Annotations: map[string]string{
+				securityv1alpha1.LegacyActiveAnnotation: "true",
+			},
+		},
+		Data: map[string][]byte{
+			DefaultKeyField: []byte(private),
+			keyPublicField:  []byte(recipient),
+		},
+	}, nil
+}
+
+// publicRecipients returns the recipients listed in a key Secret's "public"
+// field, one per line, skipping blank lines and # comments.
+func publicRecipients(secret *corev1.Secret) []string {
+	var recipients []string
+	for _, line := range strings.Split(string(secret.Data[keyPublicField]), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		recipients = append(recipients, line)
+	}
+	return recipients
+}
+
+// keyStoreHandler feeds key Secret informer events into the KeyStore and the
+// key inventory metrics.
+func keyStoreHandler(store *KeyStore, isKeySecret func(*corev1.Secret) bool) toolscache.ResourceEventHandler {
+	return toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if s, ok := obj.(*corev1.Secret); ok && isKeySecret(s) {
+				store.Upsert(s)
+				keySecretInventory.observe(s)
+			}
+		},
+		UpdateFunc: func(_, obj interface{}) {
+			s, ok := obj.(*corev1.Secret)
+			if !ok {
+				return
+			}
+			if isKeySecret(s) {
+				store.Upsert(s)
+				keySecretInventory.observe(s)
+			} else {
+				// The key label was removed.
+				store.Delete(s.UID)
+				keySecretInventory.forget(s.UID)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if s, ok := obj.(*corev1.Secret); ok {
+				store.Delete(s.UID)
+				keySecretInventory.forget(s.UID)
+			}
+		},
+	}
+}
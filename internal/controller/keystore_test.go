@@ -0,0 +1,177 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"time"
+
+	age "filippo.io/age"
+	"filippo.io/age/agessh"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"golang.org/x/crypto/ssh"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	toolscache "k8s.io/client-go/tools/cache"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+)
+
+func newIdentity() *age.X25519Identity {
+	id, err := age.GenerateX25519Identity()
+	Expect(err).NotTo(HaveOccurred())
+	return id
+}
+
+var _ = Describe("KeyStore", func() {
+	It("parses a key Secret once per resourceVersion", func() {
+		store := NewKeyStore()
+		secret, id := newKeySecret("age-key-cache")
+		secret.UID = types.UID("uid-1")
+		secret.ResourceVersion = "1"
+		keys := []corev1.Secret{*secret}
+
+		before := testutil.ToFloat64(keyStoreRefreshes)
+		ring := store.Keyring(context.Background(), keys)
+		Expect(ring.Len()).To(Equal(1))
+		store.Keyring(context.Background(), keys)
+		Expect(testutil.ToFloat64(keyStoreRefreshes) - before).To(Equal(1.0))
+
+		By("changing the resourceVersion")
+		keys[0].ResourceVersion = "2"
+		plain, key, err := store.Keyring(context.Background(), keys).
+			Decrypt(encryptArmored("cached", id.Recipient()))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(plain)).To(Equal("cached"))
+		Expect(key.Source).To(Equal("age-key-cache"))
+		Expect(testutil.ToFloat64(keyStoreRefreshes) - before).To(Equal(2.0))
+
+		By("deleting the key Secret")
+		store.Delete(secret.UID)
+		Expect(store.Len()).To(BeZero())
+	})
+
+	It("skips key Secrets without a parsable identity", func() {
+		secret, _ := newKeySecret("age-key-broken")
+		secret.Data["private"] = []byte("not-a-key")
+		Expect(NewKeyStore().Keyring(context.Background(), []corev1.Secret{*secret}).Len()).To(BeZero())
+	})
+
+	It("parses passphrase-protected SSH keys from a key Secret", func() {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		block, err := ssh.MarshalPrivateKeyWithPassphrase(priv, "deploy-key", []byte("hunter2"))
+		Expect(err).NotTo(HaveOccurred())
+		sshPub, err := ssh.NewPublicKey(pub)
+		Expect(err).NotTo(HaveOccurred())
+		recipient, err := agessh.NewEd25519Recipient(sshPub)
+		Expect(err).NotTo(HaveOccurred())
+
+		secret, _ := newKeySecret("deploy-key")
+		secret.Data = map[string][]byte{
+			"private":    pem.EncodeToMemory(block),
+			"passphrase": []byte("hunter2"),
+		}
+		plain, key, err := NewKeyStore().Keyring(context.Background(), []corev1.Secret{*secret}).
+			Decrypt(encryptArmored("via ssh", recipient))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(plain)).To(Equal("via ssh"))
+		Expect(key.Source).To(Equal("deploy-key"))
+
+		By("forcing the x25519 type via annotation")
+		secret.Annotations[securityv1alpha1.KeyTypeAnnotation] = securityv1alpha1.KeyTypeX25519
+		_, err = parseKeySecret(secret, []string{DefaultKeyField})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("reads several identities from identity files in the configured fields", func() {
+		current, predecessor, extra := newIdentity(), newIdentity(), newIdentity()
+		secret, _ := newKeySecret("age-key-bundle")
+		secret.Data = map[string][]byte{
+			"private": []byte("# created: 2025-01-01T00:00:00Z\n" +
+				"# public key: " + current.Recipient().String() + "\n" +
+				current.String() + "\n\n" +
+				"# predecessor\n" + predecessor.String() + "\n"),
+			"legacy": []byte(extra.String()),
+		}
+
+		ids, err := parseKeySecret(secret, []string{DefaultKeyField})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids).To(HaveLen(2))
+
+		ring := NewKeyStore(DefaultKeyField, "legacy").Keyring(context.Background(), []corev1.Secret{*secret})
+		Expect(ring.Len()).To(Equal(3))
+		plain, _, err := ring.Decrypt(encryptArmored("old", predecessor.Recipient()))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(plain)).To(Equal("old"))
+
+		_, err = parseKeySecret(secret, []string{"identities"})
+		Expect(err).To(MatchError(ContainSubstring("missing 'identities' field")))
+	})
+
+	It("keeps the key gauges current from informer events alone", func() {
+		handler := keyStoreHandler(NewKeyStore(), func(s *corev1.Secret) bool {
+			return s.Labels[testKeyLabelKey] == testKeyLabelVal
+		})
+		older, _ := newKeySecret("age-key-older")
+		older.UID = types.UID("inventory-1")
+		older.CreationTimestamp = metav1.NewTime(time.Now().Add(-48 * time.Hour))
+		newer, _ := newKeySecret("age-key-newer")
+		newer.UID = types.UID("inventory-2")
+		newer.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+
+		By("adding keys, as rotation does")
+		handler.OnAdd(older, false)
+		handler.OnAdd(newer, false)
+		Expect(testutil.ToFloat64(keySecrets)).To(Equal(2.0))
+		Expect(testutil.ToFloat64(newestKeyAge)).To(BeNumerically("~", time.Hour.Seconds(), 60))
+
+		By("removing the key label by hand")
+		unlabelled := newer.DeepCopy()
+		delete(unlabelled.Labels, testKeyLabelKey)
+		handler.OnUpdate(newer, unlabelled)
+		Expect(testutil.ToFloat64(keySecrets)).To(Equal(1.0))
+		Expect(testutil.ToFloat64(newestKeyAge)).To(BeNumerically("~", (48 * time.Hour).Seconds(), 60))
+
+		By("deleting the last key, as pruning does")
+		handler.OnDelete(toolscache.DeletedFinalStateUnknown{Key: "age-key-older", Obj: older})
+		Expect(testutil.ToFloat64(keySecrets)).To(BeZero())
+		Expect(testutil.ToFloat64(newestKeyAge)).To(BeZero())
+	})
+
+	DescribeTable("reads the key lifecycle from annotations",
+		func(annotations map[string]string, want securityv1alpha1.KeyState) {
+			secret := &corev1.Secret{}
+			secret.Annotations = annotations
+			Expect(keyState(secret)).To(Equal(want))
+		},
+		Entry("no annotation", nil, securityv1alpha1.KeyStateActive),
+		Entry("rotation job", map[string]string{"active": "true"}, securityv1alpha1.KeyStateActive),
+		Entry("deactivated", map[string]string{"active": "false"}, securityv1alpha1.KeyStateRetired),
+		Entry("key-state wins", map[string]string{
+			"active": "true", securityv1alpha1.KeyStateAnnotation: "Retired",
+		}, securityv1alpha1.KeyStateRetired),
+		Entry("revoked", map[string]string{securityv1alpha1.KeyStateAnnotation: "revoked"}, securityv1alpha1.KeyStateRevoked),
+		Entry("unknown fails closed", map[string]string{securityv1alpha1.KeyStateAnnotation: "actve"}, securityv1alpha1.KeyStateRevoked),
+	)
+})
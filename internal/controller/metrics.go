@@ -0,0 +1,243 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+package controller
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+)
+
+const metricsNamespace = "sealed_age"
+
+var (
+	// decryptAttempts counts every field the controller tried to decrypt.
+	decryptAttempts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "decrypt_attempts_total",
+		Help:      "Number of encryptedData fields the controller attempted to decrypt.",
+	})
+
+	// decryptFailures counts fields that could not be decrypted, by condition reason.
+	decryptFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "decrypt_failures_total",
+		Help:      "Number of encryptedData fields that could not be decrypted, by reason.",
+	}, []string{"reason"})
+
+	// decryptDuration observes how long decrypting a single field took.
+	decryptDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "decrypt_duration_seconds",
+		Help:      "Time spent decrypting a single encryptedData field.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+	})
+
+	// decryptsByKey counts successful decrypts per key Secret.
+	decryptsByKey = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "decrypts_by_key_total",
+		Help:      "Number of fields successfully decrypted, by key Secret.",
+	}, []string{"key_secret"})
+
+	// keySecrets reports how many key Secrets were discovered in the key namespace.
+	keySecrets = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "key_secrets",
+		Help:      "Number of AGE key Secrets discovered in the key namespace.",
+	})
+
+	// newestKeyCreation holds the creation time (unix seconds) of the newest key Secret.
+	newestKeyCreation atomic.Int64
+
+	// newestKeyAge is computed at scrape time so it keeps growing when rotation stops.
+	newestKeyAge = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "newest_key_age_seconds",
+		Help:      "Age of the newest AGE key Secret, based on its creation timestamp.",
+	}, func() float64 {
+		created := newestKeyCreation.Load()
+		if created == 0 {
+			return 0
+		}
+		return time.Since(time.Unix(created, 0)).Seconds()
+	})
+
+	// keyStoreEntries reports how many key Secrets are cached in the KeyStore.
+	keyStoreEntries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "key_store_entries",
+		Help:      "Number of key Secrets whose parsed identities are cached.",
+	})
+
+	// keyStoreRefreshes counts key Secret (re)parses caused by new or changed Secrets.
+	keyStoreRefreshes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "key_store_refreshes_total",
+		Help:      "Number of times a key Secret was (re)parsed into the identity cache.",
+	})
+
+	// keyPruneCandidates reports how many keys the last prune pass found unused.
+	keyPruneCandidates = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "key_prune_candidates",
+		Help:      "Number of key Secrets the last prune pass found unused (pruned, or prunable in dry-run mode).",
+	})
+
+	// sealedAgesByReady reports the number of SealedAges per Ready condition status.
+	sealedAgesByReady = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "sealedages",
+		Help:      "Number of SealedAges by Ready condition status.",
+	}, []string{"ready"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		decryptAttempts,
+		decryptFailures,
+		decryptDuration,
+		decryptsByKey,
+		keySecrets,
+		newestKeyAge,
+		keyStoreEntries,
+		keyStoreRefreshes,
+		keyPruneCandidates,
+		sealedAgesByReady,
+	)
+}
+
+// keyInventory tracks the creation time of every key Secret the informer
+// sees, so the key gauges follow rotation, pruning and manual changes on every
+// replica, with or without SealedAges to reconcile.
+type keyInventory struct {
+	mu      sync.Mutex
+	created map[types.UID]time.Time
+}
+
+var keySecretInventory = &keyInventory{created: map[types.UID]time.Time{}}
+
+// observe records a new or changed key Secret.
+func (k *keyInventory) observe(s *corev1.Secret) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.created[s.UID] = s.CreationTimestamp.Time
+	k.publish()
+}
+
+// forget drops a deleted key Secret, or one that lost the key label.
+func (k *keyInventory) forget(uid types.UID) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.created, uid)
+	k.publish()
+}
+
+func (k *keyInventory) publish() {
+	keySecrets.Set(float64(len(k.created)))
+	var newest time.Time
+	for _, ts := range k.created {
+		if ts.After(newest) {
+			newest = ts
+		}
+	}
+	if newest.IsZero() {
+		newestKeyCreation.Store(0)
+		return
+	}
+	newestKeyCreation.Store(newest.Unix())
+}
+
+// observeDecryptFailures counts n fields that failed before decryption could start.
+func observeDecryptFailures(reason string, n int) {
+	decryptAttempts.Add(float64(n))
+	decryptFailures.WithLabelValues(reason).Add(float64(n))
+}
+
+// readyTracker remembers the Ready status of every SealedAge the informer
+// sees, so sealedAgesByReady is current on every replica, leader or not,
+// without listing the whole cluster.
+type readyTracker struct {
+	mu       sync.Mutex
+	statuses map[types.NamespacedName]metav1.ConditionStatus
+}
+
+var sealedAgeReadiness = &readyTracker{statuses: map[types.NamespacedName]metav1.ConditionStatus{}}
+
+// observe records the Ready status of a SealedAge.
+func (t *readyTracker) observe(nn types.NamespacedName, status metav1.ConditionStatus) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.statuses[nn] = status
+	t.publish()
+}
+
+// forget drops a deleted SealedAge.
+func (t *readyTracker) forget(nn types.NamespacedName) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.statuses, nn)
+	t.publish()
+}
+
+func (t *readyTracker) publish() {
+	counts := map[metav1.ConditionStatus]int{
+		metav1.ConditionTrue:    0,
+		metav1.ConditionFalse:   0,
+		metav1.ConditionUnknown: 0,
+	}
+	for _, s := range t.statuses {
+		counts[s]++
+	}
+	for s, n := range counts {
+		sealedAgesByReady.WithLabelValues(string(s)).Set(float64(n))
+	}
+}
+
+// readyHandler feeds SealedAge informer events into sealedAgeReadiness. A
+// SealedAge without a Ready condition yet counts as Unknown; one that is being
+// deleted is no longer counted.
+func readyHandler() toolscache.ResourceEventHandler {
+	observe := func(obj interface{}) {
+		cr, ok := obj.(*securityv1alpha1.SealedAge)
+		if !ok {
+			return
+		}
+		nn := client.ObjectKeyFromObject(cr)
+		if !cr.DeletionTimestamp.IsZero() {
+			sealedAgeReadiness.forget(nn)
+			return
+		}
+		status := metav1.ConditionUnknown
+		if ready := meta.FindStatusCondition(cr.Status.Conditions, securityv1alpha1.ConditionReady); ready != nil {
+			status = ready.Status
+		}
+		sealedAgeReadiness.observe(nn, status)
+	}
+	return toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    observe,
+		UpdateFunc: func(_, obj interface{}) { observe(obj) },
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if cr, ok := obj.(*securityv1alpha1.SealedAge); ok {
+				sealedAgeReadiness.forget(client.ObjectKeyFromObject(cr))
+			}
+		},
+	}
+}
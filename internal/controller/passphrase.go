@@ -0,0 +1,66 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+	"github.com/callmewhatuwant/sealed-age-operator/internal/agecrypt"
+)
+
+const (
+	// defaultPassphraseKey is read when a PassphraseRef has no key.
+	defaultPassphraseKey = "passphrase"
+	// DefaultMaxScryptWorkFactor caps the scrypt cost of passphrase-encrypted
+	// fields; `age -p` writes 18, every step above doubles CPU and memory.
+	DefaultMaxScryptWorkFactor = 20
+)
+
+// passphraseKeyring returns a Keyring holding the scrypt identity for ref,
+// read from a Secret in the key namespace.
+func (r *SealedAgeReconciler) passphraseKeyring(ctx context.Context, ref securityv1alpha1.PassphraseRef) (*agecrypt.Keyring, error) {
+	key := ref.Key
+	if key == "" {
+		key = defaultPassphraseKey
+	}
+	var secret corev1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: r.KeyNamespace}, &secret); err != nil {
+		return nil, fmt.Errorf("passphrase secret %s/%s: %w", r.KeyNamespace, ref.Name, err)
+	}
+	pw, ok := secret.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("passphrase secret %s/%s has no key %q", r.KeyNamespace, ref.Name, key)
+	}
+
+	workFactor := r.MaxScryptWorkFactor
+	if workFactor == 0 {
+		workFactor = DefaultMaxScryptWorkFactor
+	}
+	id, err := agecrypt.NewPassphraseIdentity(pw, workFactor)
+	if err != nil {
+		return nil, fmt.Errorf("passphrase secret %s/%s: %w", r.KeyNamespace, ref.Name, err)
+	}
+	ring := &agecrypt.Keyring{}
+	ring.Add(ref.Name, id)
+	return ring, nil
+}
+
+// needsKeys counts the encryptedData fields that are decrypted with key
+// Secrets, i.e. that have no passphrase reference.
+func needsKeys(cr *securityv1alpha1.SealedAge) int {
+	n := 0
+	for field := range cr.Spec.EncryptedData {
+		if _, ok := cr.Spec.PassphraseRefs[field]; !ok {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+)
+
+// pruneInterval is how often the KeyPruner looks for unused keys.
+const pruneInterval = time.Hour
+
+// PruneMode selects what the KeyPruner does with unused keys.
+type PruneMode string
+
+const (
+	// PruneModeDelete deletes unused keys (and their AgeKeys).
+	PruneModeDelete PruneMode = "delete"
+	// PruneModeRetire only marks unused active keys retired.
+	PruneModeRetire PruneMode = "retire"
+)
+
+// KeyPruner removes key Secrets that no SealedAge in the cluster still needs.
+// A key is unused when none of its identities can unwrap a recipient stanza
+// of any encryptedData field, so the decision rests on the ciphertext itself,
+// not on status. It runs only on the leader.
+//
+// Only rotated keys are pruned; keys added by hand or declared with an AgeKey
+// are left alone. Keys younger than Retention (counted from retired-at for retired keys),
+// revoked keys, keys annotated with security.age.io/keep and the newest
+// active key are never pruned.
+type KeyPruner struct {
+	client.Client
+
+	KeyNamespace string
+	KeyLabelKey  string
+	KeyLabelVal  string
+
+	// Retention is the minimum age of a key before it can be pruned.
+	Retention time.Duration
+	// Mode is delete (default) or retire.
+	Mode PruneMode
+	// DryRun only reports the keys that would be pruned.
+	DryRun bool
+
+	// Recorder emits Kubernetes Events on key Secrets (optional).
+	Recorder record.EventRecorder
+
+	// Keys caches parsed key identities; shared with the controllers.
+	// Start creates one when unset.
+	Keys *KeyStore
+
+	now clock
+}
+
+// NeedLeaderElection makes the manager run the pruner on the leader only.
+func (p *KeyPruner) NeedLeaderElection() bool {
+	return true
+}
+
+// Start runs a prune pass immediately and then every pruneInterval.
+func (p *KeyPruner) Start(ctx context.Context) error {
+	if p.Keys == nil {
+		p.Keys = NewKeyStore()
+	}
+	logger := log.FromContext(ctx).WithName("key-pruner")
+	ctx = log.IntoContext(ctx, logger)
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		if err := p.prune(ctx); err != nil {
+			logger.Error(err, "key pruning failed")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// prune runs one pass over the key Secrets.
+func (p *KeyPruner) prune(ctx context.Context) error {
+	logger := log.FromContext(ctx)
+	now := p.now.Now()
+
+	var keys corev1.SecretList
+	if err := p.List(ctx, &keys,
+		client.InNamespace(p.KeyNamespace),
+		client.MatchingLabels{p.KeyLabelKey: p.KeyLabelVal},
+	); err != nil {
+		return fmt.Errorf("list key secrets: %w", err)
+	}
+	var sealedAges securityv1alpha1.SealedAgeList
+	if err := p.List(ctx, &sealedAges); err != nil {
+		return fmt.Errorf("list sealedages: %w", err)
+	}
+	headers := parseSealedHeaders(sealedAges.Items)
+	// Protect the key the rotator would keep: a newer key added by hand or
+	// through an AgeKey doesn't replace it, so pruning it would only make the
+	// rotator create another one.
+	var rotated []corev1.Secret
+	for i := range keys.Items {
+		if isRotatedKey(&keys.Items[i]) {
+			rotated = append(rotated, keys.Items[i])
+		}
+	}
+	lastActive := newestActiveKey(rotated)
+
+	candidates := 0
+	for i := range keys.Items {
+		s := &keys.Items[i]
+		state := keyState(s)
+		switch {
+		case s.Name == lastActive,
+			state == securityv1alpha1.KeyStateRevoked,
+			state == securityv1alpha1.KeyStateRetired && p.mode() == PruneModeRetire,
+			!isRotatedKey(s),
+			s.Annotations[securityv1alpha1.KeepKeyAnnotation] == "true",
+			now.Sub(keyAgeSince(s)) < p.Retention:
+			continue
+		}
+		e := p.Keys.get(s)
+		if e.err != nil {
+			// Keys we can't parse can't be checked against the ciphertext.
+			logger.V(1).Info("not pruning unparsable key secret", "secret", s.Name, "err", e.err)
+			continue
+		}
+		refs, err := referencingSealedAges(headers, e.identities)
+		if err != nil {
+			// A failed unwrap doesn't tell whether the ciphertext needs this key.
+			logger.Info("not pruning key secret, ciphertext could not be checked", "secret", s.Name, "err", err.Error())
+			continue
+		}
+		if len(refs) > 0 {
+			logger.V(1).Info("key secret still in use", "secret", s.Name, "sealedAges", len(refs))
+			continue
+		}
+
+		candidates++
+		if p.DryRun {
+			logger.Info("key secret would be pruned (dry run)", "secret", s.Name, "mode", p.mode())
+			recordEvent(p.Recorder, s, corev1.EventTypeNormal, EventReasonKeyPrunable,
+				"no SealedAge is sealed to this key, it would be %sd (dry run)", p.mode())
+			continue
+		}
+		if p.mode() == PruneModeRetire {
+			if err := retireKey(ctx, p.Client, s, now); err != nil {
+				return fmt.Errorf("retire %s: %w", s.Name, err)
+			}
+			logger.Info("retired unused key secret", "secret", s.Name)
+			recordEvent(p.Recorder, s, corev1.EventTypeNormal, EventReasonKeyRetired, "no SealedAge is sealed to this key")
+			continue
+		}
+		if err := deleteKey(ctx, p.Client, s); err != nil {
+			return err
+		}
+		logger.Info("deleted unused key secret", "secret", s.Name)
+		recordEvent(p.Recorder, s, corev1.EventTypeNormal, EventReasonKeyDeleted,
+			"no SealedAge is sealed to this key, older than retention %s", p.Retention)
+	}
+	keyPruneCandidates.Set(float64(candidates))
+	return nil
+}
+
+// newestActiveKey returns the name of the most recently created active key.
+// Timestamps have second precision; ties go to the greater name, which for
+// generated age-key-<date> names is the later one.
+func newestActiveKey(keys []corev1.Secret) string {
+	var name string
+	var newest time.Time
+	for i := range keys {
+		if keyState(&keys[i]) != securityv1alpha1.KeyStateActive {
+			continue
+		}
+		ts := keys[i].CreationTimestamp.Time
+		if name == "" || ts.After(newest) || (ts.Equal(newest) && keys[i].Name > name) {
+			name, newest = keys[i].Name, ts
+		}
+	}
+	return name
+}
+
+// keyAgeSince returns when the key's retention starts: when it was retired,
+// or when it was created.
+func keyAgeSince(s *corev1.Secret) time.Time {
+	if at, err := time.Parse(time.RFC3339, s.Annotations[securityv1alpha1.KeyRetiredAtAnnotation]); err == nil {
+		return at
+	}
+	return s.CreationTimestamp.Time
+}
+
+func (p *KeyPruner) mode() PruneMode {
+	if p.Mode == "" {
+		return PruneModeDelete
+	}
+	return p.Mode
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	age "filippo.io/age"
+	"filippo.io/age/plugin"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+)
+
+var _ = Describe("KeyPruner", func() {
+	ctx := context.Background()
+	const retention = 24 * time.Hour
+
+	var pruner *KeyPruner
+	var ids map[string]*age.X25519Identity
+
+	// Every key is created now; the pruner runs two retention periods later.
+	createKey := func(name string, annotations map[string]string) {
+		s, id := newKeySecret(name)
+		for k, v := range annotations {
+			s.Annotations[k] = v
+		}
+		Expect(k8sClient.Create(ctx, s)).To(Succeed())
+		ids[name] = id
+	}
+	keyNames := func() []string {
+		var list corev1.SecretList
+		Expect(k8sClient.List(ctx, &list, client.InNamespace(testKeyNamespace),
+			client.MatchingLabels{testKeyLabelKey: testKeyLabelVal})).To(Succeed())
+		var names []string
+		for i := range list.Items {
+			names = append(names, list.Items[i].Name)
+		}
+		return names
+	}
+	getKey := func(name string) *corev1.Secret {
+		var s corev1.Secret
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: name, Namespace: testKeyNamespace}, &s)).To(Succeed())
+		return &s
+	}
+
+	BeforeEach(func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testKeyNamespace}}
+		if err := k8sClient.Create(ctx, ns); err != nil && !errors.IsAlreadyExists(err) {
+			Expect(err).NotTo(HaveOccurred())
+		}
+		later := time.Now().Add(2 * retention)
+		pruner = &KeyPruner{
+			Client:       k8sClient,
+			KeyNamespace: testKeyNamespace,
+			KeyLabelKey:  testKeyLabelKey,
+			KeyLabelVal:  testKeyLabelVal,
+			Retention:    retention,
+			Recorder:     record.NewFakeRecorder(32),
+			Keys:         NewKeyStore(),
+			now:          func() time.Time { return later },
+		}
+		ids = map[string]*age.X25519Identity{}
+
+		retired := map[string]string{securityv1alpha1.LegacyActiveAnnotation: "false"}
+		createKey("age-key-used", retired)
+		createKey("age-key-unused", retired)
+		createKey("age-key-recently-retired", map[string]string{
+			securityv1alpha1.LegacyActiveAnnotation: "false",
+			securityv1alpha1.KeyRetiredAtAnnotation: later.Add(-time.Hour).UTC().Format(time.RFC3339),
+		})
+		createKey("age-key-revoked", map[string]string{
+			securityv1alpha1.KeyStateAnnotation: string(securityv1alpha1.KeyStateRevoked),
+		})
+		createKey("age-key-kept", map[string]string{
+			securityv1alpha1.LegacyActiveAnnotation: "false",
+			securityv1alpha1.KeepKeyAnnotation:      "true",
+		})
+		createKey("deploy-key", nil)
+
+		cr := &securityv1alpha1.SealedAge{
+			ObjectMeta: metav1.ObjectMeta{Name: "sealed-to-old-key", Namespace: "default"},
+			Spec: securityv1alpha1.SealedAgeSpec{EncryptedData: map[string]string{
+				"password": encryptArmored("s3cr3t", ids["age-key-used"].Recipient()),
+				"broken":   "not age",
+			}},
+		}
+		Expect(k8sClient.Create(ctx, cr)).To(Succeed())
+
+		DeferCleanup(func() {
+			Expect(k8sClient.Delete(ctx, cr)).To(Succeed())
+			Expect(k8sClient.DeleteAllOf(ctx, &corev1.Secret{}, client.InNamespace(testKeyNamespace),
+				client.MatchingLabels{testKeyLabelKey: testKeyLabelVal})).To(Succeed())
+		})
+	})
+
+	It("deletes old keys no ciphertext is sealed to", func() {
+		Expect(pruner.prune(ctx)).To(Succeed())
+		Expect(keyNames()).To(ConsistOf(
+			"age-key-used", "age-key-recently-retired", "age-key-revoked", "age-key-kept",
+			"deploy-key", // added by hand
+		))
+		Expect(testutil.ToFloat64(keyPruneCandidates)).To(Equal(1.0))
+		Expect(<-pruner.Recorder.(*record.FakeRecorder).Events).To(ContainSubstring("KeyDeleted"))
+	})
+
+	It("keeps the newest active key but prunes older active ones", func() {
+		// Same creation second: the greater name counts as the newer key.
+		createKey("age-key-a-active", nil)
+		createKey("age-key-b-newest", nil)
+		Expect(pruner.prune(ctx)).To(Succeed())
+		names := keyNames()
+		Expect(names).To(ContainElements("age-key-b-newest", "deploy-key"), "deploy-key was added by hand")
+		Expect(names).NotTo(ContainElements("age-key-a-active", "age-key-unused"))
+	})
+
+	It("keeps the newest rotated key when a key added by hand is newer", func() {
+		createKey("age-key-only-active", nil)
+		// Same creation second: the greater name would count as the newer key.
+		createKey("zz-deploy-key", nil)
+		Expect(pruner.prune(ctx)).To(Succeed())
+		Expect(keyNames()).To(ContainElements("age-key-only-active", "zz-deploy-key"))
+	})
+
+	It("keeps keys whose use can't be checked", func() {
+		s, _ := newKeySecret("age-key-plugin")
+		s.Annotations[securityv1alpha1.LegacyActiveAnnotation] = "false"
+		s.Data["private"] = []byte(plugin.EncodeIdentity("missing", []byte("slot-1")))
+		Expect(k8sClient.Create(ctx, s)).To(Succeed())
+		Expect(pruner.prune(ctx)).To(Succeed())
+		Expect(keyNames()).To(ContainElement("age-key-plugin"))
+		Expect(keyNames()).NotTo(ContainElement("age-key-unused"))
+	})
+
+	It("only reports in dry-run mode", func() {
+		pruner.DryRun = true
+		before := keyNames()
+		Expect(pruner.prune(ctx)).To(Succeed())
+		Expect(keyNames()).To(ConsistOf(before))
+		Expect(<-pruner.Recorder.(*record.FakeRecorder).Events).To(
+			ContainSubstring("KeyPrunable no SealedAge is sealed to this key, it would be deleted (dry run)"))
+	})
+
+	It("retires unused active keys in retire mode", func() {
+		pruner.Mode = PruneModeRetire
+		createKey("age-key-a-active", nil)
+		createKey("age-key-b-newest", nil)
+		Expect(pruner.prune(ctx)).To(Succeed())
+		Expect(keyNames()).To(ContainElements("age-key-unused", "age-key-a-active"))
+		Expect(keyState(getKey("age-key-a-active"))).To(Equal(securityv1alpha1.KeyStateRetired))
+		Expect(keyState(getKey("age-key-b-newest"))).To(Equal(securityv1alpha1.KeyStateActive))
+		Expect(keyState(getKey("deploy-key"))).To(Equal(securityv1alpha1.KeyStateActive), "added by hand, left alone")
+	})
+})
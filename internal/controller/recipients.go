@@ -0,0 +1,163 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+)
+
+// RecipientsServer serves the recipients of the active key Secrets over HTTP,
+// so users can seal data without read access to the key namespace. Only the
+// "public" field of key Secrets is ever read. It runs on every replica.
+type RecipientsServer struct {
+	client.Reader
+
+	KeyNamespace string
+	KeyLabelKey  string
+	KeyLabelVal  string
+
+	// BindAddress is the address the server listens on, e.g. ":8090".
+	BindAddress string
+}
+
+// RecipientInfo describes one recipient in the JSON response.
+type RecipientInfo struct {
+	Recipient string                    `json:"recipient"`
+	KeySecret string                    `json:"keySecret"`
+	State     securityv1alpha1.KeyState `json:"state"`
+	CreatedAt time.Time                 `json:"createdAt"`
+}
+
+// recipientsResponse is the JSON body of /recipients.json.
+type recipientsResponse struct {
+	Recipients []RecipientInfo `json:"recipients"`
+}
+
+// NeedLeaderElection lets every replica serve recipients.
+func (s *RecipientsServer) NeedLeaderElection() bool {
+	return false
+}
+
+// Start serves until ctx is cancelled.
+func (s *RecipientsServer) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("recipients-server")
+	ln, err := net.Listen("tcp", s.BindAddress)
+	if err != nil {
+		return fmt.Errorf("recipients server: %w", err)
+	}
+	srv := &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	logger.Info("serving recipients", "address", ln.Addr().String())
+	if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Handler serves /recipients as a recipients file for `age -R` (or JSON when
+// the client asks for application/json) and /recipients.json as JSON.
+func (s *RecipientsServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/recipients", func(w http.ResponseWriter, req *http.Request) {
+		s.serve(w, req, strings.Contains(req.Header.Get("Accept"), "application/json"))
+	})
+	mux.HandleFunc("/recipients.json", func(w http.ResponseWriter, req *http.Request) {
+		s.serve(w, req, true)
+	})
+	return mux
+}
+
+func (s *RecipientsServer) serve(w http.ResponseWriter, req *http.Request, asJSON bool) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	recipients, err := activeRecipients(req.Context(), s.Reader, s.KeyNamespace, s.KeyLabelKey, s.KeyLabelVal)
+	if err != nil {
+		log.FromContext(req.Context()).Error(err, "failed to list recipients")
+		http.Error(w, "failed to list recipients", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if asJSON {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(recipientsResponse{Recipients: recipients})
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte(recipientsFile(recipients)))
+}
+
+// recipientsFile formats recipients as a recipients file for `age -R`, with
+// a comment naming the key Secret above each recipient.
+func recipientsFile(recipients []RecipientInfo) string {
+	var b strings.Builder
+	for _, r := range recipients {
+		fmt.Fprintf(&b, "# %s (%s, created %s)\n%s\n", r.KeySecret, r.State, r.CreatedAt.UTC().Format(time.RFC3339), r.Recipient)
+	}
+	return b.String()
+}
+
+// activeRecipients returns the recipients of the active key Secrets, newest
+// key first. Key Secrets without a "public" field are left out.
+func activeRecipients(
+	ctx context.Context, c client.Reader, keyNamespace, keyLabelKey, keyLabelVal string,
+) ([]RecipientInfo, error) {
+	var list corev1.SecretList
+	if err := c.List(ctx, &list,
+		client.InNamespace(keyNamespace),
+		client.MatchingLabels{keyLabelKey: keyLabelVal},
+	); err != nil {
+		return nil, err
+	}
+	recipients := []RecipientInfo{}
+	for i := range list.Items {
+		secret := &list.Items[i]
+		if !secret.DeletionTimestamp.IsZero() || keyState(secret) != securityv1alpha1.KeyStateActive {
+			continue
+		}
+		for _, rcpt := range publicRecipients(secret) {
+			recipients = append(recipients, RecipientInfo{
+				Recipient: rcpt,
+				KeySecret: secret.Name,
+				State:     securityv1alpha1.KeyStateActive,
+				CreatedAt: secret.CreationTimestamp.Time,
+			})
+		}
+	}
+	sort.SliceStable(recipients, func(i, j int) bool {
+		if !recipients[i].CreatedAt.Equal(recipients[j].CreatedAt) {
+			return recipients[i].CreatedAt.After(recipients[j].CreatedAt)
+		}
+		return recipients[i].KeySecret > recipients[j].KeySecret
+	})
+	return recipients, nil
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+)
+
+// RecipientsConfigMapReconciler keeps a ConfigMap with the active recipients,
+// in age recipients-file format, in every namespace matching NamespaceSelector.
+// Only the "public" field of key Secrets is read.
+type RecipientsConfigMapReconciler struct {
+	client.Client
+
+	// Configurable via CLI flags (see cmd/main.go)
+	KeyNamespace string // default: "sealed-age-system"
+	KeyLabelKey  string // default: "app"
+	KeyLabelVal  string // default: "age-key"
+
+	// Name of the ConfigMap written to each namespace (default: "age-recipients").
+	Name string
+	// NamespaceSelector selects the namespaces that get the ConfigMap (default: all).
+	NamespaceSelector labels.Selector
+}
+
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+func (r *RecipientsConfigMapReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithValues("namespace", req.Name)
+
+	var ns corev1.Namespace
+	if err := r.Get(ctx, types.NamespacedName{Name: req.Name}, &ns); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !ns.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	cm := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Name: r.Name, Namespace: ns.Name}, cm)
+	exists := err == nil
+	if err != nil && !apierrors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	if exists && !isManagedRecipients(cm) {
+		logger.Info("not touching foreign configmap", "configmap", r.Name)
+		return ctrl.Result{}, nil
+	}
+
+	if !r.selected(&ns) {
+		if exists {
+			return ctrl.Result{}, client.IgnoreNotFound(r.Delete(ctx, cm))
+		}
+		return ctrl.Result{}, nil
+	}
+
+	recipients, err := activeRecipients(ctx, r.Client, r.KeyNamespace, r.KeyLabelKey, r.KeyLabelVal)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("list recipients: %w", err)
+	}
+	data := map[string]string{securityv1alpha1.RecipientsConfigMapKey: recipientsFile(recipients)}
+
+	if !exists {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      r.Name,
+				Namespace: ns.Name,
+				Labels: map[string]string{
+					securityv1alpha1.ManagedByLabel:  securityv1alpha1.ManagedByValue,
+					securityv1alpha1.RecipientsLabel: "true",
+				},
+			},
+			Data: data,
+		}
+		// A foreign ConfigMap with that name is not in the cache, creating it fails.
+		return ctrl.Result{}, client.IgnoreAlreadyExists(r.Create(ctx, cm))
+	}
+	if maps.Equal(cm.Data, data) && cm.Labels[securityv1alpha1.RecipientsLabel] == "true" {
+		return ctrl.Result{}, nil
+	}
+	cm.Data = data
+	cm.Labels[securityv1alpha1.RecipientsLabel] = "true"
+	logger.V(1).Info("updating recipients configmap", "recipients", len(recipients))
+	return ctrl.Result{}, r.Update(ctx, cm)
+}
+
+// selected reports whether the namespace should get the ConfigMap.
+func (r *RecipientsConfigMapReconciler) selected(ns *corev1.Namespace) bool {
+	return r.NamespaceSelector == nil || r.NamespaceSelector.Matches(labels.Set(ns.Labels))
+}
+
+// isManagedRecipients reports whether the operator wrote the ConfigMap.
+func isManagedRecipients(cm *corev1.ConfigMap) bool {
+	return cm.Labels[securityv1alpha1.ManagedByLabel] == securityv1alpha1.ManagedByValue
+}
+
+func (r *RecipientsConfigMapReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Name == "" {
+		r.Name = "age-recipients"
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("recipientsconfigmap").
+		For(&corev1.Namespace{}).
+		// Repair edited or deleted ConfigMaps. The manager caches only
+		// ConfigMaps with the managed-by label (see cmd/main.go).
+		Watches(&corev1.ConfigMap{},
+			handler.EnqueueRequestsFromMapFunc(func(_ context.Context, obj client.Object) []reconcile.Request {
+				return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: obj.GetNamespace()}}}
+			}),
+			builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+				return obj.GetName() == r.Name
+			})),
+		).
+		// Key rotation changes the recipients of every namespace.
+		Watches(&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.allNamespaces),
+			builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+				return obj.GetNamespace() == r.KeyNamespace && obj.GetLabels()[r.KeyLabelKey] == r.KeyLabelVal
+			})),
+		).
+		Complete(r)
+}
+
+// RecipientsConfigMapCleaner deletes the recipients ConfigMaps left behind when
+// the feature was turned off or the ConfigMap renamed. It runs once, on the leader.
+type RecipientsConfigMapCleaner struct {
+	client.Client
+
+	// Name of the ConfigMap still published; empty deletes all of them.
+	Name string
+}
+
+// NeedLeaderElection makes the manager run the cleaner on the leader only.
+func (c *RecipientsConfigMapCleaner) NeedLeaderElection() bool {
+	return true
+}
+
+// Start deletes the stale ConfigMaps. Failures are only logged, they must not
+// stop the manager.
+func (c *RecipientsConfigMapCleaner) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("recipients-configmap-cleaner")
+	var list corev1.ConfigMapList
+	if err := c.List(ctx, &list, client.MatchingLabels{
+		securityv1alpha1.ManagedByLabel:  securityv1alpha1.ManagedByValue,
+		securityv1alpha1.RecipientsLabel: "true",
+	}); err != nil {
+		logger.Error(err, "failed to list recipients configmaps")
+		return nil
+	}
+	for i := range list.Items {
+		cm := &list.Items[i]
+		if cm.Name == c.Name {
+			continue
+		}
+		if err := c.Delete(ctx, cm); client.IgnoreNotFound(err) != nil {
+			logger.Error(err, "failed to delete stale recipients configmap", "configmap", client.ObjectKeyFromObject(cm))
+			continue
+		}
+		logger.Info("deleted stale recipients configmap", "configmap", client.ObjectKeyFromObject(cm))
+	}
+	return nil
+}
+
+// allNamespaces enqueues every namespace when a key Secret changes.
+func (r *RecipientsConfigMapReconciler) allNamespaces(ctx context.Context, _ client.Object) []reconcile.Request {
+	var list corev1.NamespaceList
+	if err := r.List(ctx, &list); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list namespaces for key secret change")
+		return nil
+	}
+	reqs := make([]reconcile.Request, 0, len(list.Items))
+	for i := range list.Items {
+		reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: list.Items[i].Name}})
+	}
+	return reqs
+}
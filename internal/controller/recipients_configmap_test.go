@@ -0,0 +1,144 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+)
+
+var _ = Describe("RecipientsConfigMap Controller", func() {
+	ctx := context.Background()
+	const cmName = "age-recipients"
+
+	var r *RecipientsConfigMapReconciler
+	var team *corev1.Namespace
+
+	reconcileNamespace := func(name string) {
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: name}})
+		Expect(err).NotTo(HaveOccurred())
+	}
+	getConfigMap := func() (*corev1.ConfigMap, error) {
+		var cm corev1.ConfigMap
+		err := k8sClient.Get(ctx, types.NamespacedName{Name: cmName, Namespace: team.Name}, &cm)
+		return &cm, err
+	}
+
+	BeforeEach(func() {
+		for _, name := range []string{testKeyNamespace, "team-a"} {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+			if err := k8sClient.Create(ctx, ns); err != nil && !errors.IsAlreadyExists(err) {
+				Expect(err).NotTo(HaveOccurred())
+			}
+		}
+		team = &corev1.Namespace{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "team-a"}, team)).To(Succeed())
+
+		r = &RecipientsConfigMapReconciler{
+			Client:       k8sClient,
+			KeyNamespace: testKeyNamespace,
+			KeyLabelKey:  testKeyLabelKey,
+			KeyLabelVal:  testKeyLabelVal,
+			Name:         cmName,
+		}
+		DeferCleanup(func() {
+			Expect(client.IgnoreNotFound(k8sClient.Delete(ctx,
+				&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: cmName, Namespace: team.Name}}))).To(Succeed())
+			Expect(k8sClient.DeleteAllOf(ctx, &corev1.Secret{}, client.InNamespace(testKeyNamespace),
+				client.MatchingLabels{testKeyLabelKey: testKeyLabelVal})).To(Succeed())
+		})
+	})
+
+	It("publishes the active recipients and follows rotation", func() {
+		first, _ := newKeySecret("age-key-first")
+		Expect(k8sClient.Create(ctx, first)).To(Succeed())
+		reconcileNamespace(team.Name)
+
+		cm, err := getConfigMap()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cm.Labels).To(HaveKeyWithValue(securityv1alpha1.ManagedByLabel, securityv1alpha1.ManagedByValue))
+		Expect(cm.Labels).To(HaveKeyWithValue(securityv1alpha1.RecipientsLabel, "true"))
+		Expect(cm.Data[securityv1alpha1.RecipientsConfigMapKey]).To(ContainSubstring(string(first.Data["public"])))
+
+		By("rotating the key")
+		second, _ := newKeySecret("age-key-second")
+		Expect(k8sClient.Create(ctx, second)).To(Succeed())
+		first.Annotations[securityv1alpha1.LegacyActiveAnnotation] = "false"
+		Expect(k8sClient.Update(ctx, first)).To(Succeed())
+		Expect(r.allNamespaces(ctx, second)).To(ContainElement(
+			reconcile.Request{NamespacedName: types.NamespacedName{Name: team.Name}}))
+		reconcileNamespace(team.Name)
+
+		cm, err = getConfigMap()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cm.Data[securityv1alpha1.RecipientsConfigMapKey]).To(ContainSubstring(string(second.Data["public"])))
+		Expect(cm.Data[securityv1alpha1.RecipientsConfigMapKey]).NotTo(ContainSubstring(string(first.Data["public"])))
+	})
+
+	It("honours the namespace selector and leaves foreign ConfigMaps alone", func() {
+		key, _ := newKeySecret("age-key-selector")
+		Expect(k8sClient.Create(ctx, key)).To(Succeed())
+		reconcileNamespace(team.Name)
+		_, err := getConfigMap()
+		Expect(err).NotTo(HaveOccurred())
+
+		By("removing it from namespaces that no longer match")
+		r.NamespaceSelector = labels.SelectorFromSet(labels.Set{"sealed-age.io/recipients": "true"})
+		reconcileNamespace(team.Name)
+		_, err = getConfigMap()
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+
+		By("not touching a ConfigMap it did not create")
+		foreign := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: cmName, Namespace: team.Name},
+			Data:       map[string]string{"recipients": "mine"},
+		}
+		Expect(k8sClient.Create(ctx, foreign)).To(Succeed())
+		r.NamespaceSelector = nil
+		reconcileNamespace(team.Name)
+		cm, err := getConfigMap()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cm.Data).To(HaveKeyWithValue("recipients", "mine"))
+	})
+
+	It("cleans up ConfigMaps of a former name or a disabled feature", func() {
+		key, _ := newKeySecret("age-key-cleanup")
+		Expect(k8sClient.Create(ctx, key)).To(Succeed())
+		reconcileNamespace(team.Name)
+
+		By("keeping the ConfigMap in use")
+		Expect((&RecipientsConfigMapCleaner{Client: k8sClient, Name: cmName}).Start(ctx)).To(Succeed())
+		_, err := getConfigMap()
+		Expect(err).NotTo(HaveOccurred())
+
+		By("deleting it once the name changed")
+		Expect((&RecipientsConfigMapCleaner{Client: k8sClient, Name: "team-recipients"}).Start(ctx)).To(Succeed())
+		_, err = getConfigMap()
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+	})
+})
@@ -0,0 +1,114 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+	"github.com/callmewhatuwant/sealed-age-operator/internal/agecrypt"
+)
+
+var _ = Describe("RecipientsServer", func() {
+	ctx := context.Background()
+
+	var handler http.Handler
+	var active, retired *corev1.Secret
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	BeforeEach(func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testKeyNamespace}}
+		if err := k8sClient.Create(ctx, ns); err != nil && !errors.IsAlreadyExists(err) {
+			Expect(err).NotTo(HaveOccurred())
+		}
+		active, _ = newKeySecret("age-key-active")
+		retired, _ = newKeySecret("age-key-retired")
+		retired.Annotations[securityv1alpha1.LegacyActiveAnnotation] = "false"
+		noPublic, _ := newKeySecret("age-key-no-public")
+		delete(noPublic.Data, "public")
+		for _, s := range []*corev1.Secret{active, retired, noPublic} {
+			Expect(k8sClient.Create(ctx, s)).To(Succeed())
+		}
+		DeferCleanup(func() {
+			Expect(k8sClient.DeleteAllOf(ctx, &corev1.Secret{}, client.InNamespace(testKeyNamespace),
+				client.MatchingLabels{testKeyLabelKey: testKeyLabelVal})).To(Succeed())
+		})
+
+		handler = (&RecipientsServer{
+			Reader:       k8sClient,
+			KeyNamespace: testKeyNamespace,
+			KeyLabelKey:  testKeyLabelKey,
+			KeyLabelVal:  testKeyLabelVal,
+		}).Handler()
+	})
+
+	It("serves the active recipients as a recipients file", func() {
+		rec := get("/recipients", "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Header().Get("Content-Type")).To(HavePrefix("text/plain"))
+		body := rec.Body.String()
+		Expect(body).To(ContainSubstring("# age-key-active (active, created "))
+		Expect(body).NotTo(ContainSubstring(string(retired.Data["public"])))
+
+		recipients, err := agecrypt.ParseRecipients(strings.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recipients).To(HaveLen(1))
+	})
+
+	It("serves JSON", func() {
+		for _, rec := range []*httptest.ResponseRecorder{
+			get("/recipients.json", ""),
+			get("/recipients", "application/json"),
+		} {
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Header().Get("Content-Type")).To(Equal("application/json"))
+			var resp recipientsResponse
+			Expect(json.Unmarshal(rec.Body.Bytes(), &resp)).To(Succeed())
+			Expect(resp.Recipients).To(HaveLen(1))
+			Expect(resp.Recipients[0].Recipient).To(Equal(string(active.Data["public"])))
+			Expect(resp.Recipients[0].KeySecret).To(Equal(active.Name))
+			Expect(resp.Recipients[0].State).To(Equal(securityv1alpha1.KeyStateActive))
+		}
+	})
+
+	It("only allows reads", func() {
+		req := httptest.NewRequest(http.MethodPost, "/recipients", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		Expect(rec.Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+})
@@ -0,0 +1,86 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+package controller
+
+import (
+	"errors"
+	"fmt"
+
+	age "filippo.io/age"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+	"github.com/callmewhatuwant/sealed-age-operator/internal/agecrypt"
+)
+
+// sealedHeader holds the parsed recipient stanzas of one encryptedData field.
+type sealedHeader struct {
+	sealedAge types.NamespacedName
+	field     string
+	stanzas   []*age.Stanza
+}
+
+// parseSealedHeaders parses the header of every encryptedData field. Fields
+// that do not parse are skipped; their SealedAge reports them as DecryptFailed.
+func parseSealedHeaders(sealedAges []securityv1alpha1.SealedAge) []sealedHeader {
+	var headers []sealedHeader
+	for i := range sealedAges {
+		nn := client.ObjectKeyFromObject(&sealedAges[i])
+		for field, enc := range sealedAges[i].Spec.EncryptedData {
+			stanzas, err := agecrypt.ParseHeader(enc)
+			if err != nil {
+				continue
+			}
+			headers = append(headers, sealedHeader{sealedAge: nn, field: field, stanzas: stanzas})
+		}
+	}
+	return headers
+}
+
+// referencingSealedAges returns the SealedAges with at least one field sealed
+// to one of ids. X25519 stanzas do not name their recipient, so every identity
+// tries to unwrap every header (one ECDH per pair, no payload is decrypted).
+//
+// Only age.ErrIncorrectIdentity means a field is not sealed to an identity.
+// Any other unwrap error, e.g. from a plugin that is missing or crashed, leaves
+// it open; the first such error is returned along with the SealedAges found.
+func referencingSealedAges(headers []sealedHeader, ids []age.Identity) ([]types.NamespacedName, error) {
+	seen := map[types.NamespacedName]bool{}
+	var refs []types.NamespacedName
+	var firstErr error
+	for _, h := range headers {
+		if seen[h.sealedAge] {
+			continue
+		}
+		sealed, err := sealedTo(h.stanzas, ids)
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s field %s: %w", h.sealedAge, h.field, err)
+		}
+		if sealed {
+			seen[h.sealedAge] = true
+			refs = append(refs, h.sealedAge)
+		}
+	}
+	return refs, firstErr
+}
+
+// sealedTo reports whether one of ids unwraps stanzas. The error is the first
+// unwrap failure other than age.ErrIncorrectIdentity, if none of ids matched.
+func sealedTo(stanzas []*age.Stanza, ids []age.Identity) (bool, error) {
+	var unwrapErr error
+	for _, id := range ids {
+		_, err := id.Unwrap(stanzas)
+		switch {
+		case err == nil:
+			return true, nil
+		case errors.Is(err, age.ErrIncorrectIdentity):
+		case unwrapErr == nil:
+			unwrapErr = err
+		}
+	}
+	return false, unwrapErr
+}
@@ -0,0 +1,381 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	age "filippo.io/age"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/yaml"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+	"github.com/callmewhatuwant/sealed-age-operator/internal/agecrypt"
+)
+
+const (
+	// resealExportSuffix names the ConfigMap holding the resealed manifest (ResealExport).
+	resealExportSuffix = "-resealed"
+	// resealExportKey is the ConfigMap data key of the resealed manifest.
+	resealExportKey = "sealedage.yaml"
+)
+
+// resealTarget holds the recipients fields should be sealed to.
+type resealTarget struct {
+	recipients []age.Recipient
+	// names are the sorted recipient strings, as recorded in status.sealedRecipients.
+	names []string
+	// active holds the identities of every active key Secret, each of which
+	// must be able to unwrap a field for it to count as current.
+	active [][]age.Identity
+}
+
+// reseal re-encrypts the fields that are not sealed to the current recipients
+// (the active keys plus spec.recipients), as selected by spec.resealPolicy.
+// It runs after the Secret was synced, so a failed reseal never blocks it.
+// fieldKeys maps every field decrypted with a key Secret to that Secret.
+func (r *SealedAgeReconciler) reseal(
+	ctx context.Context, cr *securityv1alpha1.SealedAge, plain map[string][]byte,
+	fieldKeys map[string]string, keySecrets []corev1.Secret,
+) error {
+	policy := cr.Spec.ResealPolicy
+	if policy == "" || policy == securityv1alpha1.ResealNever {
+		return nil
+	}
+	target, err := r.resealTarget(ctx, cr, keySecrets)
+	if err != nil {
+		return err
+	}
+	if len(target.active) == 0 {
+		// Nothing to move the data to yet, e.g. during a passphrase bootstrap.
+		return nil
+	}
+
+	if policy == securityv1alpha1.ResealExport {
+		applied, err := r.exportApplied(ctx, cr)
+		if err != nil {
+			return err
+		}
+		if applied != nil {
+			cr.Status.SealedRecipients = applied
+		}
+	}
+
+	fields := staleFields(cr, fieldKeys, keySecrets, target)
+	if len(fields) == 0 {
+		if policy == securityv1alpha1.ResealExport {
+			return r.deleteExport(ctx, cr)
+		}
+		return nil
+	}
+
+	if policy == securityv1alpha1.ResealExport {
+		current, err := r.exportCurrent(ctx, cr, target.names)
+		if err != nil || current {
+			return err
+		}
+	}
+
+	resealed := cr.Spec.DeepCopy()
+	for _, field := range fields {
+		ct, err := agecrypt.Encrypt(plain[field], target.recipients...)
+		if err != nil {
+			return fmt.Errorf("reseal %s: %w", field, err)
+		}
+		resealed.EncryptedData[field] = ct
+		// The field is no longer passphrase-encrypted.
+		delete(resealed.PassphraseRefs, field)
+	}
+
+	if policy == securityv1alpha1.ResealExport {
+		return r.writeExport(ctx, cr, resealed, target.names)
+	}
+
+	// Update returns the stored status; keep the conditions computed so far.
+	status := cr.Status.DeepCopy()
+	cr.Spec = *resealed
+	if cr.Annotations == nil {
+		cr.Annotations = map[string]string{}
+	}
+	cr.Annotations[securityv1alpha1.SealedRecipientsAnnotation] = strings.Join(target.names, ",")
+	if err := r.Update(ctx, cr); err != nil {
+		return err
+	}
+	// The status describes the resealed spec: don't report it a generation behind.
+	cr.Status = *status
+	cr.Status.ObservedGeneration = cr.Generation
+	for i := range cr.Status.Conditions {
+		cr.Status.Conditions[i].ObservedGeneration = cr.Generation
+	}
+	cr.Status.SealedRecipients = target.names
+	log.FromContext(ctx).Info("resealed fields", "fields", fields, "recipients", target.names)
+	recordEvent(r.Recorder, cr, corev1.EventTypeNormal, EventReasonResealed, "resealed field(s) %s to %d recipient(s)",
+		strings.Join(fields, ", "), len(target.names))
+	return nil
+}
+
+// resealTarget collects the recipients of the active key Secrets and spec.recipients.
+// A key Secret's recipients come from its "public" field, or are derived from
+// its X25519 identities.
+func (r *SealedAgeReconciler) resealTarget(
+	ctx context.Context, cr *securityv1alpha1.SealedAge, keySecrets []corev1.Secret,
+) (*resealTarget, error) {
+	target := &resealTarget{}
+	seen := map[string]bool{}
+	add := func(name string, rcpt age.Recipient) {
+		if !seen[name] {
+			seen[name] = true
+			target.names = append(target.names, name)
+			target.recipients = append(target.recipients, rcpt)
+		}
+	}
+
+	for i := range keySecrets {
+		s := &keySecrets[i]
+		if keyState(s) != securityv1alpha1.KeyStateActive {
+			continue
+		}
+		e := r.Keys.get(s)
+		if e.err != nil {
+			continue
+		}
+		names := publicRecipients(s)
+		if len(names) == 0 {
+			for _, id := range e.identities {
+				if x, ok := id.(*age.X25519Identity); ok {
+					names = append(names, x.Recipient().String())
+				}
+			}
+		}
+		if len(names) == 0 {
+			log.FromContext(ctx).V(1).Info("active key has no recipient, set its public field", "secret", s.Name)
+			continue
+		}
+		for _, name := range names {
+			rcpt, err := agecrypt.ParseRecipient(name)
+			if err != nil {
+				return nil, fmt.Errorf("key secret %s: %w", s.Name, err)
+			}
+			add(name, rcpt)
+		}
+		target.active = append(target.active, e.identities)
+	}
+
+	for _, name := range cr.Spec.Recipients {
+		name = strings.TrimSpace(name)
+		rcpt, err := agecrypt.ParseRecipient(name)
+		if err != nil {
+			return nil, fmt.Errorf("spec.recipients: %w", err)
+		}
+		add(name, rcpt)
+	}
+	slices.Sort(target.names)
+	return target, nil
+}
+
+// staleFields returns the sorted fields that need a reseal: all of them when
+// the recipients changed since the last reseal, otherwise passphrase fields,
+// fields decrypted with a key that is no longer active and fields that not
+// every active key can decrypt.
+func staleFields(
+	cr *securityv1alpha1.SealedAge, fieldKeys map[string]string, keySecrets []corev1.Secret, target *resealTarget,
+) []string {
+	all := !slices.Equal(cr.Status.SealedRecipients, target.names)
+	states := map[string]securityv1alpha1.KeyState{}
+	for i := range keySecrets {
+		states[keySecrets[i].Name] = keyState(&keySecrets[i])
+	}
+
+	var fields []string
+	for field, enc := range cr.Spec.EncryptedData {
+		key, ok := fieldKeys[field]
+		if all || !ok || states[key] != securityv1alpha1.KeyStateActive || !sealedToAll(enc, target.active) {
+			fields = append(fields, field)
+		}
+	}
+	slices.Sort(fields)
+	return fields
+}
+
+// sealedToAll reports whether every identity set can unwrap the file's header.
+func sealedToAll(enc string, active [][]age.Identity) bool {
+	stanzas, err := agecrypt.ParseHeader(enc)
+	if err != nil {
+		return false
+	}
+	for _, ids := range active {
+		ring := &agecrypt.Keyring{}
+		ring.Add("", ids...)
+		if _, err := ring.Match(stanzas); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// writeExport stores the resealed manifest in the export ConfigMap. An export
+// already made for this generation and these recipients is kept, since
+// resealing again would only produce different ciphertext.
+func (r *SealedAgeReconciler) writeExport(
+	ctx context.Context, cr *securityv1alpha1.SealedAge, resealed *securityv1alpha1.SealedAgeSpec, recipients []string,
+) error {
+	generation := strconv.FormatInt(cr.Generation, 10)
+	joined := strings.Join(recipients, ",")
+
+	existing, err := r.getExport(ctx, cr)
+	if err != nil {
+		return err
+	}
+	if existing != nil && !metav1.IsControlledBy(existing, cr) {
+		return &exportConflictError{name: existing.Name}
+	}
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: cr.Name + resealExportSuffix, Namespace: cr.Namespace}}
+	manifest := securityv1alpha1.SealedAge{
+		TypeMeta: metav1.TypeMeta{APIVersion: securityv1alpha1.GroupVersion.String(), Kind: "SealedAge"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        cr.Name,
+			Namespace:   cr.Namespace,
+			Labels:      cr.Labels,
+			Annotations: map[string]string{securityv1alpha1.SealedRecipientsAnnotation: joined},
+		},
+		Spec: *resealed,
+	}
+	out, err := yaml.Marshal(&manifest)
+	if err != nil {
+		return err
+	}
+	// Drop the empty status and creationTimestamp, the manifest goes to git.
+	out, err = trimManifest(out)
+	if err != nil {
+		return err
+	}
+
+	op, err := controllerutil.CreateOrUpdate(ctx, r.Client, cm, func() error {
+		if cm.Annotations == nil {
+			cm.Annotations = map[string]string{}
+		}
+		if cm.Labels == nil {
+			cm.Labels = map[string]string{}
+		}
+		cm.Labels[securityv1alpha1.ManagedByLabel] = securityv1alpha1.ManagedByValue
+		cm.Annotations[securityv1alpha1.ResealedGenerationAnnotation] = generation
+		cm.Annotations[securityv1alpha1.ResealedRecipientsAnnotation] = joined
+		cm.Data = map[string]string{resealExportKey: string(out)}
+		return controllerutil.SetControllerReference(cr, cm, r.Scheme)
+	})
+	if err != nil {
+		return err
+	}
+	if op != controllerutil.OperationResultNone {
+		recordEvent(r.Recorder, cr, corev1.EventTypeNormal, EventReasonResealed,
+			"exported resealed manifest to ConfigMap %s, commit it to replace this SealedAge", cm.Name)
+	}
+	return nil
+}
+
+// exportCurrent reports whether the export ConfigMap already holds this
+// generation resealed to recipients, so the fields need not be encrypted again.
+func (r *SealedAgeReconciler) exportCurrent(
+	ctx context.Context, cr *securityv1alpha1.SealedAge, recipients []string,
+) (bool, error) {
+	cm, err := r.getExport(ctx, cr)
+	if err != nil || cm == nil {
+		return false, err
+	}
+	return metav1.IsControlledBy(cm, cr) &&
+		cm.Annotations[securityv1alpha1.ResealedGenerationAnnotation] == strconv.FormatInt(cr.Generation, 10) &&
+		cm.Annotations[securityv1alpha1.ResealedRecipientsAnnotation] == strings.Join(recipients, ","), nil
+}
+
+// exportApplied checks whether spec.encryptedData is the one from the export
+// ConfigMap, i.e. the resealed manifest was committed and synced. It then
+// deletes the export and returns the recipients it was sealed to.
+func (r *SealedAgeReconciler) exportApplied(ctx context.Context, cr *securityv1alpha1.SealedAge) ([]string, error) {
+	cm, err := r.getExport(ctx, cr)
+	if err != nil || cm == nil || !metav1.IsControlledBy(cm, cr) {
+		return nil, err
+	}
+	var exported securityv1alpha1.SealedAge
+	if err := yaml.Unmarshal([]byte(cm.Data[resealExportKey]), &exported); err != nil {
+		return nil, nil
+	}
+	if !maps.Equal(exported.Spec.EncryptedData, cr.Spec.EncryptedData) {
+		return nil, nil
+	}
+	if err := r.deleteExport(ctx, cr); err != nil {
+		return nil, err
+	}
+	recordEvent(r.Recorder, cr, corev1.EventTypeNormal, EventReasonResealed, "resealed manifest was applied")
+	recipients := cm.Annotations[securityv1alpha1.ResealedRecipientsAnnotation]
+	if recipients == "" {
+		return []string{}, nil
+	}
+	return strings.Split(recipients, ","), nil
+}
+
+// deleteExport removes the export ConfigMap, if this SealedAge owns one.
+func (r *SealedAgeReconciler) deleteExport(ctx context.Context, cr *securityv1alpha1.SealedAge) error {
+	cm, err := r.getExport(ctx, cr)
+	if err != nil || cm == nil || !metav1.IsControlledBy(cm, cr) {
+		return err
+	}
+	return client.IgnoreNotFound(r.Delete(ctx, cm))
+}
+
+// getExport returns the ConfigMap with the export's name, or nil. It reads past
+// the cache, which only holds operator ConfigMaps: a user's ConfigMap of that
+// name must be seen, or creating the export fails on every reconcile.
+func (r *SealedAgeReconciler) getExport(ctx context.Context, cr *securityv1alpha1.SealedAge) (*corev1.ConfigMap, error) {
+	reader := r.APIReader
+	if reader == nil {
+		reader = r.Client
+	}
+	var cm corev1.ConfigMap
+	err := reader.Get(ctx, types.NamespacedName{Name: cr.Name + resealExportSuffix, Namespace: cr.Namespace}, &cm)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cm, nil
+}
+
+// exportConflictError means a ConfigMap the SealedAge doesn't own has the
+// export's name. It is reported, not retried with backoff.
+type exportConflictError struct {
+	name string
+}
+
+func (e *exportConflictError) Error() string {
+	return fmt.Sprintf("ConfigMap %s exists and is not owned by this SealedAge, "+
+		"delete or rename it to export the resealed manifest", e.name)
+}
+
+// trimManifest removes the fields yaml.Marshal emits for zero structs.
+func trimManifest(b []byte) ([]byte, error) {
+	var obj map[string]interface{}
+	if err := yaml.Unmarshal(b, &obj); err != nil {
+		return nil, err
+	}
+	delete(obj, "status")
+	if md, ok := obj["metadata"].(map[string]interface{}); ok {
+		delete(md, "creationTimestamp")
+	}
+	return yaml.Marshal(obj)
+}
@@ -0,0 +1,265 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+)
+
+const (
+	// keyNamePrefix and keyNameLayout name generated keys, so they sort by creation time.
+	keyNamePrefix = "age-key-"
+	keyNameLayout = "2006-01-02-15-04-05"
+
+	// minRotationCheck and maxRotationCheck bound how long the rotator sleeps
+	// between passes; the upper bound picks up keys changed by hand.
+	minRotationCheck = time.Minute
+	maxRotationCheck = time.Hour
+)
+
+// KeyRotator generates a new X25519 key Secret every Interval and retires the
+// keys it replaces; the KeyPruner deletes them once unused. It runs only on
+// the leader.
+//
+// Only rotated keys are touched: Secrets carrying the rotated label, plus
+// keys created by the former rotation job (they have the "active" annotation).
+// Other key Secrets, e.g. SSH deploy keys or keys of user AgeKeys, are left alone.
+type KeyRotator struct {
+	client.Client
+
+	KeyNamespace string
+	KeyLabelKey  string
+	KeyLabelVal  string
+
+	// Interval between two keys; 0 disables rotation.
+	Interval time.Duration
+
+	// AgeKeys creates an AgeKey for every new key; set when the CRD is installed.
+	AgeKeys bool
+
+	// Recorder emits Kubernetes Events on key Secrets (optional).
+	Recorder record.EventRecorder
+
+	now clock
+}
+
+// NeedLeaderElection makes the manager run the rotator on the leader only.
+func (k *KeyRotator) NeedLeaderElection() bool {
+	return true
+}
+
+// Start runs rotation passes until ctx is cancelled. The first pass runs
+// immediately, so a fresh install gets its first key without a Job.
+func (k *KeyRotator) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("key-rotator")
+	ctx = log.IntoContext(ctx, logger)
+	for {
+		wait, err := k.rotate(ctx)
+		if err != nil {
+			logger.Error(err, "key rotation failed")
+			wait = minRotationCheck
+		}
+		wait = min(max(wait, minRotationCheck), maxRotationCheck)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// rotate runs one pass and returns the time until the next key is due.
+func (k *KeyRotator) rotate(ctx context.Context) (time.Duration, error) {
+	logger := log.FromContext(ctx)
+	now := k.now.Now()
+
+	var list corev1.SecretList
+	if err := k.List(ctx, &list,
+		client.InNamespace(k.KeyNamespace),
+		client.MatchingLabels{k.KeyLabelKey: k.KeyLabelVal},
+	); err != nil {
+		return 0, fmt.Errorf("list key secrets: %w", err)
+	}
+
+	var active []corev1.Secret
+	for i := range list.Items {
+		s := &list.Items[i]
+		if isRotatedKey(s) && keyState(s) == securityv1alpha1.KeyStateActive {
+			active = append(active, *s)
+		}
+	}
+
+	if newest := newestActiveKey(active); newest != "" {
+		var due time.Time
+		for i := range active {
+			if active[i].Name == newest {
+				due = active[i].CreationTimestamp.Add(k.Interval)
+			}
+		}
+		if now.Before(due) {
+			// Finish a rotation whose retire step failed in an earlier pass.
+			for i := range active {
+				if active[i].Name == newest {
+					continue
+				}
+				if err := k.retire(ctx, &active[i], now); err != nil {
+					return 0, fmt.Errorf("retire %s: %w", active[i].Name, err)
+				}
+			}
+			return due.Sub(now), nil
+		}
+	}
+
+	key, err := k.createKey(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+	logger.Info("created key secret", "secret", key.Name)
+
+	var retired []string
+	for i := range active {
+		if err := k.retire(ctx, &active[i], now); err != nil {
+			return 0, fmt.Errorf("retire %s: %w", active[i].Name, err)
+		}
+		retired = append(retired, active[i].Name)
+	}
+	msg := "created key " + key.Name
+	if len(retired) > 0 {
+		msg += ", retired " + strings.Join(retired, ", ")
+	}
+	recordEvent(k.Recorder, key, corev1.EventTypeNormal, EventReasonKeyRotated, "%s", msg)
+	return k.Interval, nil
+}
+
+// createKey writes a new key Secret and, with AgeKeys, the AgeKey modelling it.
+// The AgeKey controller adopts the Secret.
+func (k *KeyRotator) createKey(ctx context.Context, now time.Time) (*corev1.Secret, error) {
+	name := keyNamePrefix + now.UTC().Format(keyNameLayout)
+	secret, err := generateKeySecret(k.KeyNamespace, name, map[string]string{
+		k.KeyLabelKey:                    k.KeyLabelVal,
+		securityv1alpha1.ManagedByLabel:  securityv1alpha1.ManagedByValue,
+		securityv1alpha1.RotatedKeyLabel: "true",
+	}, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := k.Create(ctx, secret); err != nil {
+		return nil, fmt.Errorf("create key secret %s: %w", secret.Name, err)
+	}
+	if !k.AgeKeys {
+		return secret, nil
+	}
+
+	ak := &securityv1alpha1.AgeKey{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: k.KeyNamespace},
+		Spec:       securityv1alpha1.AgeKeySpec{State: securityv1alpha1.KeyStateActive},
+	}
+	if err := k.Create(ctx, ak); err != nil {
+		log.FromContext(ctx).Info("not creating AgeKey for rotated key", "secret", name, "err", err.Error())
+	}
+	return secret, nil
+}
+
+// retire flips a replaced key to decrypt-only.
+func (k *KeyRotator) retire(ctx context.Context, s *corev1.Secret, now time.Time) error {
+	if err := retireKey(ctx, k.Client, s, now); err != nil {
+		return err
+	}
+	recordEvent(k.Recorder, s, corev1.EventTypeNormal, EventReasonKeyRetired, "retired by key rotation")
+	return nil
+}
+
+// retireKey marks a key Secret retired (and its AgeKey, if it has one). The
+// AgeKey goes first: retiring only the Secret would be undone by the AgeKey
+// controller re-applying spec.state.
+func retireKey(ctx context.Context, c client.Client, s *corev1.Secret, now time.Time) error {
+	ak, err := ageKeyOf(ctx, c, s)
+	if err != nil {
+		return err
+	}
+	if ak != nil && ak.Spec.State == securityv1alpha1.KeyStateActive {
+		ak.Spec.State = securityv1alpha1.KeyStateRetired
+		if err := c.Update(ctx, ak); err != nil {
+			return err
+		}
+	}
+	// A patch, not an update: the AgeKey controller may just have written the Secret.
+	patch := client.MergeFrom(s.DeepCopy())
+	if s.Annotations == nil {
+		s.Annotations = map[string]string{}
+	}
+	s.Annotations[securityv1alpha1.LegacyActiveAnnotation] = "false"
+	if _, ok := s.Annotations[securityv1alpha1.KeyStateAnnotation]; ok {
+		s.Annotations[securityv1alpha1.KeyStateAnnotation] = string(securityv1alpha1.KeyStateRetired)
+	}
+	s.Annotations[securityv1alpha1.KeyRetiredAtAnnotation] = now.UTC().Format(time.RFC3339)
+	return c.Patch(ctx, s, patch)
+}
+
+// deleteKey deletes a key Secret together with its AgeKey, if it has one.
+func deleteKey(ctx context.Context, c client.Client, s *corev1.Secret) error {
+	// Delete the AgeKey first, otherwise it would report its Secret missing.
+	ak, err := ageKeyOf(ctx, c, s)
+	if err != nil {
+		return err
+	}
+	if ak != nil {
+		if err := c.Delete(ctx, ak); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("delete agekey %s: %w", ak.Name, err)
+		}
+	}
+	if err := c.Delete(ctx, s); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf("delete key secret %s: %w", s.Name, err)
+	}
+	return nil
+}
+
+// ageKeyOf returns the AgeKey that generated or adopted the key Secret, or
+// nil when it has none (or the AgeKey CRD is not installed).
+func ageKeyOf(ctx context.Context, c client.Client, s *corev1.Secret) (*securityv1alpha1.AgeKey, error) {
+	name := s.Annotations[securityv1alpha1.AgeKeyAnnotation]
+	if name == "" {
+		return nil, nil
+	}
+	var ak securityv1alpha1.AgeKey
+	err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: s.Namespace}, &ak)
+	switch {
+	case apierrors.IsNotFound(err), meta.IsNoMatchError(err):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("get agekey %s: %w", name, err)
+	}
+	return &ak, nil
+}
+
+// isRotatedKey reports whether the rotator manages the key Secret: keys it
+// created carry the rotated label, keys of the old rotation CronJob only the
+// legacy "active" annotation and the key name prefix. Keys generated for or
+// adopted by a user's AgeKey never count.
+func isRotatedKey(s *corev1.Secret) bool {
+	if s.Labels[securityv1alpha1.RotatedKeyLabel] == "true" {
+		return true
+	}
+	_, legacy := s.Annotations[securityv1alpha1.LegacyActiveAnnotation]
+	return legacy && strings.HasPrefix(s.Name, keyNamePrefix) && s.Annotations[securityv1alpha1.AgeKeyAnnotation] == ""
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+)
+
+var _ = Describe("KeyRotator", func() {
+	ctx := context.Background()
+	const interval = 30 * 24 * time.Hour
+
+	var rotator *KeyRotator
+	var now time.Time
+
+	listKeys := func() []corev1.Secret {
+		var list corev1.SecretList
+		Expect(k8sClient.List(ctx, &list, client.InNamespace(testKeyNamespace),
+			client.MatchingLabels{testKeyLabelKey: testKeyLabelVal})).To(Succeed())
+		return list.Items
+	}
+
+	BeforeEach(func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testKeyNamespace}}
+		if err := k8sClient.Create(ctx, ns); err != nil && !errors.IsAlreadyExists(err) {
+			Expect(err).NotTo(HaveOccurred())
+		}
+		now = time.Now()
+		rotator = &KeyRotator{
+			Client:       k8sClient,
+			KeyNamespace: testKeyNamespace,
+			KeyLabelKey:  testKeyLabelKey,
+			KeyLabelVal:  testKeyLabelVal,
+			Interval:     interval,
+			AgeKeys:      true,
+			Recorder:     record.NewFakeRecorder(32),
+			now:          func() time.Time { return now },
+		}
+		DeferCleanup(func() {
+			Expect(k8sClient.DeleteAllOf(ctx, &securityv1alpha1.AgeKey{}, client.InNamespace(testKeyNamespace))).To(Succeed())
+			Expect(k8sClient.DeleteAllOf(ctx, &corev1.Secret{}, client.InNamespace(testKeyNamespace),
+				client.MatchingLabels{testKeyLabelKey: testKeyLabelVal})).To(Succeed())
+		})
+	})
+
+	It("creates the first key and rotates once the interval passed", func() {
+		By("creating a key on a fresh install")
+		wait, err := rotator.rotate(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(wait).To(Equal(interval))
+		keys := listKeys()
+		Expect(keys).To(HaveLen(1))
+		first := keys[0]
+		Expect(first.Name).To(HavePrefix("age-key-"))
+		Expect(first.Labels).To(HaveKeyWithValue(securityv1alpha1.ManagedByLabel, securityv1alpha1.ManagedByValue))
+		Expect(first.Labels).To(HaveKeyWithValue(securityv1alpha1.RotatedKeyLabel, "true"))
+		Expect(keyState(&first)).To(Equal(securityv1alpha1.KeyStateActive))
+		ids, err := parseKeySecret(&first, []string{DefaultKeyField})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids).To(HaveLen(1))
+		var ak securityv1alpha1.AgeKey
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(&first), &ak)).To(Succeed(), "an AgeKey models the new key")
+
+		By("doing nothing before the interval passed")
+		now = now.Add(time.Hour)
+		wait, err = rotator.rotate(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(wait).To(BeNumerically("<", interval))
+		Expect(listKeys()).To(HaveLen(1))
+
+		By("rotating after the interval")
+		now = now.Add(interval)
+		_, err = rotator.rotate(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		keys = listKeys()
+		Expect(keys).To(HaveLen(2))
+		for i := range keys {
+			if keys[i].Name == first.Name {
+				Expect(keyState(&keys[i])).To(Equal(securityv1alpha1.KeyStateRetired))
+				Expect(keys[i].Annotations).To(HaveKey(securityv1alpha1.KeyRetiredAtAnnotation))
+			} else {
+				Expect(keyState(&keys[i])).To(Equal(securityv1alpha1.KeyStateActive))
+			}
+		}
+		var events []string
+		for len(rotator.Recorder.(*record.FakeRecorder).Events) > 0 {
+			events = append(events, <-rotator.Recorder.(*record.FakeRecorder).Events)
+		}
+		Expect(events).To(ContainElements(
+			ContainSubstring("KeyRetired retired by key rotation"),
+			ContainSubstring("KeyRotated created key age-key-"),
+			MatchRegexp("KeyRotated created key .*, retired "+first.Name),
+		))
+	})
+
+	It("never rotates the keys of user AgeKeys", func() {
+		ak := &securityv1alpha1.AgeKey{
+			ObjectMeta: metav1.ObjectMeta{Name: "age-key-team-a", Namespace: testKeyNamespace},
+			Spec:       securityv1alpha1.AgeKeySpec{State: securityv1alpha1.KeyStateActive},
+		}
+		Expect(k8sClient.Create(ctx, ak)).To(Succeed())
+		_, err := (&AgeKeyReconciler{
+			Client:       k8sClient,
+			Scheme:       k8sClient.Scheme(),
+			KeyNamespace: testKeyNamespace,
+			KeyLabelKey:  testKeyLabelKey,
+			KeyLabelVal:  testKeyLabelVal,
+			Keys:         NewKeyStore(),
+		}).Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(ak)})
+		Expect(err).NotTo(HaveOccurred())
+
+		By("creating a rotated key next to it")
+		_, err = rotator.rotate(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(listKeys()).To(HaveLen(2))
+
+		By("rotating without retiring the user's key")
+		now = now.Add(interval)
+		_, err = rotator.rotate(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(listKeys()).To(HaveLen(3))
+		var secret corev1.Secret
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(ak), &secret)).To(Succeed())
+		Expect(isRotatedKey(&secret)).To(BeFalse())
+		Expect(keyState(&secret)).To(Equal(securityv1alpha1.KeyStateActive))
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(ak), ak)).To(Succeed())
+		Expect(ak.Spec.State).To(Equal(securityv1alpha1.KeyStateActive))
+
+		By("not counting user keys that only carry the managed-by label")
+		Expect(isRotatedKey(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name:   "age-key-team-b",
+			Labels: map[string]string{securityv1alpha1.ManagedByLabel: securityv1alpha1.ManagedByValue},
+		}})).To(BeFalse())
+	})
+
+	It("keeps the key active when its AgeKey can't be read", func() {
+		_, err := rotator.rotate(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		first := listKeys()[0]
+
+		By("failing the AgeKey lookup during the next rotation")
+		wc, err := client.NewWithWatch(cfg, client.Options{Scheme: k8sClient.Scheme()})
+		Expect(err).NotTo(HaveOccurred())
+		rotator.Client = interceptor.NewClient(wc, interceptor.Funcs{
+			Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+				if _, ok := obj.(*securityv1alpha1.AgeKey); ok {
+					return errors.NewServiceUnavailable("etcd is down")
+				}
+				return c.Get(ctx, key, obj, opts...)
+			},
+		})
+		now = now.Add(interval)
+		_, err = rotator.rotate(ctx)
+		Expect(err).To(MatchError(ContainSubstring("etcd is down")))
+		var secret corev1.Secret
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(&first), &secret)).To(Succeed())
+		Expect(keyState(&secret)).To(Equal(securityv1alpha1.KeyStateActive))
+
+		By("retiring it on the next pass, before another key is due")
+		rotator.Client = k8sClient
+		_, err = rotator.rotate(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(&first), &secret)).To(Succeed())
+		Expect(keyState(&secret)).To(Equal(securityv1alpha1.KeyStateRetired))
+		var ak securityv1alpha1.AgeKey
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(&first), &ak)).To(Succeed())
+		Expect(ak.Spec.State).To(Equal(securityv1alpha1.KeyStateRetired))
+		Expect(listKeys()).To(HaveLen(2))
+	})
+})
@@ -9,25 +9,30 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io"
-	"strings"
+	"maps"
+	"slices"
 	"time"
 
-	age "filippo.io/age"
-	"filippo.io/age/armor"
-
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+	"github.com/callmewhatuwant/sealed-age-operator/internal/agecrypt"
 )
 
 // SealedAgeReconciler reconciles SealedAge resources.
@@ -39,12 +44,32 @@ type SealedAgeReconciler struct {
 	KeyNamespace string // default: "sealed-age-system"
 	KeyLabelKey  string // default: "app"
 	KeyLabelVal  string // default: "age-key"
+
+	// KeyFields are the key Secret data fields holding identities (default: "private").
+	KeyFields []string
+
+	// MaxScryptWorkFactor caps the scrypt cost of passphraseRefs fields
+	// (default: DefaultMaxScryptWorkFactor).
+	MaxScryptWorkFactor int
+
+	// Recorder emits Kubernetes Events on SealedAges and generated Secrets (optional).
+	Recorder record.EventRecorder
+
+	// Keys caches parsed key identities across reconciles. SetupWithManager
+	// creates one from KeyFields when unset.
+	Keys *KeyStore
+
+	// APIReader reads export ConfigMaps past the cache, which only holds
+	// operator ConfigMaps (optional, defaults to the client).
+	APIReader client.Reader
 }
 
 // +kubebuilder:rbac:groups=security.age.io,resources=sealedages,verbs=get;list;watch;update;patch
 // +kubebuilder:rbac:groups=security.age.io,resources=sealedages/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=security.age.io,resources=sealedages/finalizers,verbs=update
-// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 func (r *SealedAgeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx).WithValues("sealedage", req.NamespacedName)
@@ -55,6 +80,16 @@ func (r *SealedAgeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	// Deletion: apply the deletion policy, then release the finalizer.
+	if !cr.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.finalize(ctx, &cr)
+	}
+	if controllerutil.AddFinalizer(&cr, securityv1alpha1.SecretFinalizer) {
+		if err := r.Update(ctx, &cr); err != nil {
+			return ctrl.Result{}, client.IgnoreNotFound(err)
+		}
+	}
+
 	// 2. List available AGE key Secrets (by namespace and label).
 	keyList := &corev1.SecretList{}
 	if err := r.List(ctx, keyList,
@@ -62,30 +97,98 @@ func (r *SealedAgeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		client.MatchingLabels{r.KeyLabelKey: r.KeyLabelVal},
 	); err != nil {
 		logger.Error(err, "failed to list key secrets", "namespace", r.KeyNamespace)
+		observeDecryptFailures(securityv1alpha1.ReasonKeyListFailed, len(cr.Spec.EncryptedData))
+		markFailed(&cr, securityv1alpha1.ConditionKeysAvailable, securityv1alpha1.ReasonKeyListFailed, err.Error())
+		r.updateStatus(ctx, &cr)
 		return ctrl.Result{}, err
 	}
-	if len(keyList.Items) == 0 {
-		logger.Info("no AGE keys found, will retry", "namespace", r.KeyNamespace)
-		return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+	switch keyFields := needsKeys(&cr); {
+	case len(keyList.Items) > 0:
+		setCondition(&cr, securityv1alpha1.ConditionKeysAvailable, metav1.ConditionTrue, securityv1alpha1.ReasonKeysFound,
+			fmt.Sprintf("%d key Secret(s) found", len(keyList.Items)))
+	case keyFields == 0:
+		// Bootstrap: every field is passphrase-encrypted, no key Secret needed yet.
+		setCondition(&cr, securityv1alpha1.ConditionKeysAvailable, metav1.ConditionTrue,
+			securityv1alpha1.ReasonKeysNotRequired, "all fields use passphraseRefs")
+	default:
+		logger.Info("no AGE keys found, waiting for a key secret", "namespace", r.KeyNamespace)
+		observeDecryptFailures(securityv1alpha1.ReasonNoKeysFound, keyFields)
+		msg := fmt.Sprintf("no key Secrets with label %s=%s in namespace %s", r.KeyLabelKey, r.KeyLabelVal, r.KeyNamespace)
+		markFailed(&cr, securityv1alpha1.ConditionKeysAvailable, securityv1alpha1.ReasonNoKeysFound, msg)
+		recordEvent(r.Recorder, &cr, corev1.EventTypeWarning, EventReasonWaitingForKeys, "waiting for keys: %s", msg)
+		r.updateStatus(ctx, &cr)
+		// No polling: the key Secret watch (see SetupWithManager) requeues us.
+		return ctrl.Result{}, nil
 	}
 
-	// 3. Decrypt each field in spec.encryptedData.
+	// 3. Decrypt each field in spec.encryptedData (identities are parsed once).
+	ring := r.Keys.Keyring(ctx, keyList.Items)
 	plain := map[string][]byte{}
+	usedKeys := map[string]bool{}
+	fieldKeys := map[string]string{}
+	decryptedWith := map[string]string{}
 	for field, enc := range cr.Spec.EncryptedData {
-		b, keyUsed, derr := decryptWithAge(ctx, enc, keyList.Items, cr.Spec.Recipients)
+		fieldRing := ring
+		if ref, ok := cr.Spec.PassphraseRefs[field]; ok {
+			pring, perr := r.passphraseKeyring(ctx, ref)
+			if perr != nil {
+				logger.Error(perr, "failed to load passphrase", "field", field)
+				observeDecryptFailures(securityv1alpha1.ReasonPassphraseMissing, 1)
+				recordEvent(r.Recorder, &cr, corev1.EventTypeWarning, securityv1alpha1.ReasonPassphraseMissing,
+					"no passphrase for field %q: %v", field, perr)
+				markFailed(&cr, securityv1alpha1.ConditionDecrypted, securityv1alpha1.ReasonPassphraseMissing,
+					fmt.Sprintf("field %q: %v", field, perr))
+				r.updateStatus(ctx, &cr)
+				// Passphrase Secrets carry no key label, so retry with backoff.
+				return ctrl.Result{}, fmt.Errorf("passphrase for %s: %w", field, perr)
+			}
+			fieldRing = pring
+		}
+
+		start := time.Now()
+		b, keyUsed, derr := decryptWithAge(ctx, enc, fieldRing)
+		decryptDuration.Observe(time.Since(start).Seconds())
+		decryptAttempts.Inc()
+		if errors.Is(derr, agecrypt.ErrKeyRevoked) {
+			logger.Info("field is only encrypted to a revoked key", "field", field, "keySecret", keyUsed)
+			decryptFailures.WithLabelValues(securityv1alpha1.ReasonKeyRevoked).Inc()
+			recordEvent(r.Recorder, &cr, corev1.EventTypeWarning, securityv1alpha1.ReasonKeyRevoked,
+				"field %q is only encrypted to revoked key secret %s", field, keyUsed)
+			markFailed(&cr, securityv1alpha1.ConditionDecrypted, securityv1alpha1.ReasonKeyRevoked,
+				fmt.Sprintf("field %q is only encrypted to revoked key secret %s, reseal it", field, keyUsed))
+			r.updateStatus(ctx, &cr)
+			// Permanent until the field is resealed or the key un-revoked; both trigger a reconcile.
+			return ctrl.Result{}, nil
+		}
 		if derr != nil {
 			logger.Error(derr, "failed to decrypt", "field", field)
+			decryptFailures.WithLabelValues(securityv1alpha1.ReasonDecryptFailed).Inc()
+			recordEvent(r.Recorder, &cr, corev1.EventTypeWarning, securityv1alpha1.ReasonDecryptFailed,
+				"no key could decrypt field %q", field)
+			markFailed(&cr, securityv1alpha1.ConditionDecrypted, securityv1alpha1.ReasonDecryptFailed,
+				fmt.Sprintf("field %q: %v", field, derr))
+			r.updateStatus(ctx, &cr)
 			return ctrl.Result{}, fmt.Errorf("decrypt %s: %w", field, derr)
 		}
 		logger.Info("decrypted field", "field", field, "keySecret", keyUsed)
+		decryptsByKey.WithLabelValues(keyUsed).Inc()
 		plain[field] = b
+		decryptedWith[field] = keyUsed
+		if fieldRing == ring {
+			usedKeys[keyUsed] = true
+			fieldKeys[field] = keyUsed
+		}
 	}
+	setCondition(&cr, securityv1alpha1.ConditionDecrypted, metav1.ConditionTrue, securityv1alpha1.ReasonSucceeded,
+		fmt.Sprintf("%d field(s) decrypted", len(plain)))
+	setKeysCurrent(&cr, usedKeys, keyList.Items)
 
-	// 4. Create or update the target Secret (same name as the CR).
-	secretName := cr.Name
+	// 4. Create or update the target Secret (template name, or same name as the CR).
+	secretName := secretNameFor(&cr)
 	secretKey := types.NamespacedName{Name: secretName, Namespace: cr.Namespace}
 	var secret corev1.Secret
 
+	var existing *corev1.Secret
 	err := r.Get(ctx, secretKey, &secret)
 	if apierrors.IsNotFound(err) {
 		secret = corev1.Secret{
@@ -95,94 +198,236 @@ func (r *SealedAgeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 			},
 		}
 	} else if err != nil {
+		markFailed(&cr, securityv1alpha1.ConditionSecretSynced, securityv1alpha1.ReasonSecretReadFailed, err.Error())
+		r.updateStatus(ctx, &cr)
 		return ctrl.Result{}, err
+	} else {
+		existing = secret.DeepCopy()
+		if msg := adoptionConflict(&cr, existing); msg != "" {
+			logger.Info("secret conflict, not adopting", "secret", secretKey.String(), "reason", msg)
+			markFailed(&cr, securityv1alpha1.ConditionSecretSynced, securityv1alpha1.ReasonSecretConflict, msg)
+			recordEvent(r.Recorder, &cr, corev1.EventTypeWarning, securityv1alpha1.ReasonSecretConflict, "%s", msg)
+			r.updateStatus(ctx, &cr)
+			return ctrl.Result{RequeueAfter: conflictRequeueAfter}, nil
+		}
+		if !metav1.IsControlledBy(existing, &cr) {
+			releaseForeignController(&cr, &secret)
+			recordEvent(r.Recorder, &cr, corev1.EventTypeNormal, EventReasonSecretAdopted,
+				"adopted existing Secret %s (adoptionPolicy %s)", secretName, adoptionPolicy(&cr))
+		}
 	}
 
-	if secret.Data == nil {
-		secret.Data = map[string][]byte{}
+	applySecretData(&secret, plain, cr.Spec.Template.MergePolicy)
+	applySecretTemplate(&secret, cr.Spec.Template)
+
+	op, werr := r.writeSecret(ctx, &cr, &secret, existing)
+	if werr != nil {
+		markFailed(&cr, securityv1alpha1.ConditionSecretSynced, securityv1alpha1.ReasonSecretWriteFailed, werr.Error())
+		recordEvent(r.Recorder, &cr, corev1.EventTypeWarning, securityv1alpha1.ReasonSecretWriteFailed,
+			"failed to write Secret %s: %v", secretName, werr)
+		r.updateStatus(ctx, &cr)
+		return ctrl.Result{}, werr
 	}
-	for k, v := range plain {
-		secret.Data[k] = v
+	// Only report decryption when it changed the Secret, resyncs would flood the events otherwise.
+	if op != controllerutil.OperationResultNone {
+		for _, field := range slices.Sorted(maps.Keys(decryptedWith)) {
+			recordEvent(r.Recorder, &cr, corev1.EventTypeNormal, EventReasonDecrypted,
+				"decrypted field %q with key secret %s", field, decryptedWith[field])
+		}
 	}
-	if t := cr.Spec.Template.Type; t != "" {
-		secret.Type = corev1.SecretType(t)
-	} else {
-		secret.Type = corev1.SecretTypeOpaque
+	switch op {
+	case controllerutil.OperationResultCreated:
+		recordEvent(r.Recorder, &cr, corev1.EventTypeNormal, EventReasonSecretCreated, "created Secret %s", secretName)
+		recordEvent(r.Recorder, &secret, corev1.EventTypeNormal, EventReasonSecretCreated,
+			"created from SealedAge %s", cr.Name)
+	case controllerutil.OperationResultUpdated:
+		recordEvent(r.Recorder, &cr, corev1.EventTypeNormal, EventReasonSecretUpdated, "updated Secret %s", secretName)
+		recordEvent(r.Recorder, &secret, corev1.EventTypeNormal, EventReasonSecretUpdated,
+			"updated from SealedAge %s", cr.Name)
+	case operationResultRecreated:
+		recordEvent(r.Recorder, &cr, corev1.EventTypeNormal, EventReasonSecretRecreated,
+			"recreated immutable Secret %s", secretName)
+		recordEvent(r.Recorder, &secret, corev1.EventTypeNormal, EventReasonSecretRecreated,
+			"recreated from SealedAge %s", cr.Name)
 	}
 
-	if err := controllerutil.SetControllerReference(&cr, &secret, r.Scheme); err != nil {
-		return ctrl.Result{}, err
-	}
-
-	if apierrors.IsNotFound(err) {
-		if err := r.Create(ctx, &secret); err != nil {
-			return ctrl.Result{}, err
-		}
-	} else {
-		if err := r.Update(ctx, &secret); err != nil {
-			return ctrl.Result{}, err
+	// The template name changed: remove the Secret generated under the old name.
+	if prev := cr.Status.SecretName; prev != "" && prev != secretName {
+		if derr := r.deletePreviousSecret(ctx, &cr, prev); derr != nil {
+			logger.Error(derr, "failed to delete previous secret", "secret", prev)
 		}
 	}
 
-	// 5. Update status — ignore NotFound, keep logs clean.
-	cr.Status.ObservedGeneration = cr.Generation
+	// 5. Reseal fields not sealed to the current recipients (spec.resealPolicy).
 	cr.Status.SecretName = secretName
+	resealErr := r.reseal(ctx, &cr, plain, fieldKeys, keyList.Items)
+	var conflict *exportConflictError
+	switch {
+	case errors.As(resealErr, &conflict):
+		logger.Info("export conflict, not exporting", "configMap", conflict.name)
+		recordEvent(r.Recorder, &cr, corev1.EventTypeWarning, securityv1alpha1.ReasonExportConflict, "%v", conflict)
+		setCondition(&cr, securityv1alpha1.ConditionResealed, metav1.ConditionFalse,
+			securityv1alpha1.ReasonExportConflict, conflict.Error())
+	case resealErr != nil:
+		logger.Error(resealErr, "failed to reseal")
+		recordEvent(r.Recorder, &cr, corev1.EventTypeWarning, securityv1alpha1.ReasonResealFailed,
+			"failed to reseal: %v", resealErr)
+		setCondition(&cr, securityv1alpha1.ConditionResealed, metav1.ConditionFalse,
+			securityv1alpha1.ReasonResealFailed, resealErr.Error())
+	case cr.Spec.ResealPolicy == "" || cr.Spec.ResealPolicy == securityv1alpha1.ResealNever:
+		meta.RemoveStatusCondition(&cr.Status.Conditions, securityv1alpha1.ConditionResealed)
+	default:
+		setCondition(&cr, securityv1alpha1.ConditionResealed, metav1.ConditionTrue, securityv1alpha1.ReasonSucceeded,
+			fmt.Sprintf("resealPolicy %s is applied", cr.Spec.ResealPolicy))
+	}
 
-	if uerr := r.Status().Update(ctx, &cr); uerr != nil {
-		if apierrors.IsNotFound(uerr) {
-			// CR was deleted before status update — ignore silently.
-			return ctrl.Result{}, nil
+	// 6. Update status.
+	setCondition(&cr, securityv1alpha1.ConditionSecretSynced, metav1.ConditionTrue, securityv1alpha1.ReasonSucceeded,
+		fmt.Sprintf("Secret %s is up to date", secretName))
+	markReady(&cr, fmt.Sprintf("Secret %s is up to date", secretName))
+	r.updateStatus(ctx, &cr)
+
+	logger.Info("reconciliation completed", "secret", secretKey.String())
+	if conflict != nil {
+		// Retrying won't help until the ConfigMap is gone, and its deletion
+		// doesn't trigger a reconcile: re-check it like a Secret conflict.
+		return ctrl.Result{RequeueAfter: conflictRequeueAfter}, nil
+	}
+	// A failed reseal doesn't affect the Secret; retry it with backoff.
+	return ctrl.Result{}, resealErr
+}
+
+// conflictRequeueAfter re-checks a Secret conflict, since an unowned Secret's
+// deletion does not trigger a reconcile.
+const conflictRequeueAfter = 2 * time.Minute
+
+// operationResultRecreated means an immutable Secret was deleted and created again.
+const operationResultRecreated controllerutil.OperationResult = "recreated"
+
+// writeSecret sets the owner reference and creates the Secret, or updates it
+// when it differs from existing (nil means the Secret does not exist yet).
+// Secrets that cannot be updated in place are deleted and created again.
+func (r *SealedAgeReconciler) writeSecret(
+	ctx context.Context, cr *securityv1alpha1.SealedAge, secret, existing *corev1.Secret,
+) (controllerutil.OperationResult, error) {
+	if err := controllerutil.SetControllerReference(cr, secret, r.Scheme); err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	if existing == nil {
+		if err := r.Create(ctx, secret); err != nil {
+			return controllerutil.OperationResultNone, err
 		}
-		logger.V(1).Info("non-fatal: failed to update status", "error", uerr)
+		return controllerutil.OperationResultCreated, nil
+	}
+	if equality.Semantic.DeepEqual(existing, secret) {
+		return controllerutil.OperationResultNone, nil
+	}
+	if needsRecreate(existing, secret) {
+		if err := r.Delete(ctx, existing); client.IgnoreNotFound(err) != nil {
+			return controllerutil.OperationResultNone, err
+		}
+		secret.ObjectMeta = metav1.ObjectMeta{
+			Name:            secret.Name,
+			Namespace:       secret.Namespace,
+			Labels:          secret.Labels,
+			Annotations:     secret.Annotations,
+			OwnerReferences: secret.OwnerReferences,
+		}
+		if err := r.Create(ctx, secret); err != nil {
+			return controllerutil.OperationResultNone, err
+		}
+		return operationResultRecreated, nil
+	}
+	if err := r.Update(ctx, secret); err != nil {
+		return controllerutil.OperationResultNone, err
 	}
+	return controllerutil.OperationResultUpdated, nil
+}
 
-	logger.Info("reconciliation completed", "secret", secretKey.String())
-	return ctrl.Result{}, nil
+// deletePreviousSecret deletes a Secret generated under an earlier template
+// name, as long as cr still controls it.
+func (r *SealedAgeReconciler) deletePreviousSecret(ctx context.Context, cr *securityv1alpha1.SealedAge, name string) error {
+	var old corev1.Secret
+	if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: cr.Namespace}, &old); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(&old, cr) {
+		return nil
+	}
+	if err := r.Delete(ctx, &old); client.IgnoreNotFound(err) != nil {
+		return err
+	}
+	recordEvent(r.Recorder, cr, corev1.EventTypeNormal, EventReasonSecretDeleted, "deleted previous Secret %s", name)
+	return nil
 }
 
 func (r *SealedAgeReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Keys == nil {
+		r.Keys = NewKeyStore(r.KeyFields...)
+	}
+	informer, err := mgr.GetCache().GetInformer(context.Background(), &corev1.Secret{})
+	if err != nil {
+		return err
+	}
+	if _, err := informer.AddEventHandler(keyStoreHandler(r.Keys, func(s *corev1.Secret) bool {
+		return r.isKeySecret(s)
+	})); err != nil {
+		return err
+	}
+	// The readiness gauge follows the cache, which every replica runs, not
+	// the reconciles, which only the leader does.
+	crInformer, err := mgr.GetCache().GetInformer(context.Background(), &securityv1alpha1.SealedAge{})
+	if err != nil {
+		return err
+	}
+	if _, err := crInformer.AddEventHandler(readyHandler()); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&securityv1alpha1.SealedAge{}).
 		Owns(&corev1.Secret{}).
+		Watches(&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForKeySecret),
+			builder.WithPredicates(predicate.NewPredicateFuncs(r.isKeySecret)),
+		).
 		Complete(r)
 }
 
-// decryptWithAge decrypts the provided armored AGE content using available key Secrets.
-func decryptWithAge(ctx context.Context, armored string, keySecrets []corev1.Secret, recipients []string) ([]byte, string, error) {
-	logger := log.FromContext(ctx)
-	for _, ks := range keySecrets {
-		name := ks.GetName()
-
-		privBytes, ok := ks.Data["private"]
-		if !ok {
-			logger.V(1).Info("missing 'private' field in key secret", "secret", name)
-			continue
-		}
-		priv := strings.TrimSpace(string(privBytes))
+// isKeySecret matches AGE key Secrets (key namespace + key label).
+func (r *SealedAgeReconciler) isKeySecret(obj client.Object) bool {
+	return obj.GetNamespace() == r.KeyNamespace && obj.GetLabels()[r.KeyLabelKey] == r.KeyLabelVal
+}
 
-		id, err := age.ParseX25519Identity(priv)
-		if err != nil {
-			logger.V(1).Info("failed to parse private identity", "secret", name, "err", err)
+// requestsForKeySecret enqueues every SealedAge that is not Ready or that was
+// decrypted with the key whenever a key Secret appears, changes or disappears,
+// so a restored key converges immediately and retiring or revoking a key is
+// reflected on the SealedAges that use it.
+func (r *SealedAgeReconciler) requestsForKeySecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	var list securityv1alpha1.SealedAgeList
+	if err := r.List(ctx, &list); err != nil {
+		log.FromContext(ctx).Error(err, "failed to list sealedages for key secret change")
+		return nil
+	}
+	var reqs []reconcile.Request
+	for i := range list.Items {
+		st := list.Items[i].Status
+		if meta.IsStatusConditionTrue(st.Conditions, securityv1alpha1.ConditionReady) &&
+			!slices.Contains(st.KeySecrets, obj.GetName()) {
 			continue
 		}
+		reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&list.Items[i])})
+	}
+	return reqs
+}
 
-		trimmed := strings.TrimLeft(armored, " \t\r\n")
-		var src io.Reader = strings.NewReader(armored)
-		if strings.HasPrefix(trimmed, "-----BEGIN AGE ENCRYPTED FILE-----") {
-			src = armor.NewReader(strings.NewReader(armored))
-		}
-
-		decR, err := age.Decrypt(src, id)
-		if err != nil {
-			logger.V(1).Info("decryption failed with this key", "secret", name, "err", err, "recipients_hint", recipients)
-			continue
-		}
-		plain, err := io.ReadAll(decR)
-		if err != nil {
-			logger.V(1).Info("failed to read decrypted data", "secret", name, "err", err)
-			continue
-		}
-		return plain, name, nil
+// decryptWithAge decrypts the provided armored AGE content. The header is parsed
+// once and the recipient stanzas select the key, so only one key decrypts.
+func decryptWithAge(ctx context.Context, armored string, ring *agecrypt.Keyring) ([]byte, string, error) {
+	plain, key, err := ring.Decrypt(armored)
+	if err != nil {
+		log.FromContext(ctx).V(1).Info("decryption failed", "keys", ring.Len(), "err", err)
+		return nil, key.Source, err
 	}
-	return nil, "", errors.New("failed to decrypt with any available key")
+	return plain, key.Source, nil
 }
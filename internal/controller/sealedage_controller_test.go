@@ -17,19 +17,96 @@ limitations under the License.
 package controller
 
 import (
+	"bytes"
 	"context"
+	"io"
+	"strings"
 
+	age "filippo.io/age"
+	"filippo.io/age/armor"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/types"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+	"github.com/callmewhatuwant/sealed-age-operator/internal/agecrypt"
 )
 
+const (
+	testKeyNamespace = "sealed-age-system"
+	testKeyLabelKey  = "app"
+	testKeyLabelVal  = "age-key"
+)
+
+// newKeySecret generates a fresh X25519 identity and wraps it in a key Secret.
+func newKeySecret(name string) (*corev1.Secret, *age.X25519Identity) {
+	id, err := age.GenerateX25519Identity()
+	Expect(err).NotTo(HaveOccurred())
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   testKeyNamespace,
+			Labels:      map[string]string{testKeyLabelKey: testKeyLabelVal},
+			Annotations: map[string]string{"active": "true"},
+		},
+		Data: map[string][]byte{
+			"private": []byte(id.String()),
+			"public":  []byte(id.Recipient().String()),
+		},
+	}, id
+}
+
+// encryptArmored encrypts plaintext to the given recipients as an armored AGE file.
+func encryptArmored(plaintext string, recipients ...age.Recipient) string {
+	var buf bytes.Buffer
+	aw := armor.NewWriter(&buf)
+	w, err := age.Encrypt(aw, recipients...)
+	Expect(err).NotTo(HaveOccurred())
+	_, err = io.WriteString(w, plaintext)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(w.Close()).To(Succeed())
+	Expect(aw.Close()).To(Succeed())
+	return buf.String()
+}
+
+func newTestReconciler() *SealedAgeReconciler {
+	return &SealedAgeReconciler{
+		Client:       k8sClient,
+		Scheme:       k8sClient.Scheme(),
+		KeyNamespace: testKeyNamespace,
+		KeyLabelKey:  testKeyLabelKey,
+		KeyLabelVal:  testKeyLabelVal,
+		Recorder:     record.NewFakeRecorder(32),
+		Keys:         NewKeyStore(),
+	}
+}
+
+// drainEvents returns every event buffered in the reconciler's FakeRecorder.
+func drainEvents(r *SealedAgeReconciler) []string {
+	var events []string
+	ch := r.Recorder.(*record.FakeRecorder).Events
+	for {
+		select {
+		case e := <-ch:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
 var _ = Describe("SealedAge Controller", func() {
 	Context("When reconciling a resource", func() {
 		const resourceName = "test-resource"
@@ -38,47 +115,540 @@ var _ = Describe("SealedAge Controller", func() {
 
 		typeNamespacedName := types.NamespacedName{
 			Name:      resourceName,
-			Namespace: "default", // TODO(user):Modify as needed
+			Namespace: "default",
 		}
-		sealedage := &securityv1alpha1.SealedAge{}
+		var keySecret *corev1.Secret
+		var identity *age.X25519Identity
 
 		BeforeEach(func() {
+			By("creating the key namespace")
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: testKeyNamespace}}
+			if err := k8sClient.Create(ctx, ns); err != nil && !errors.IsAlreadyExists(err) {
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			keySecret, identity = newKeySecret("age-key-test")
+
 			By("creating the custom resource for the Kind SealedAge")
-			err := k8sClient.Get(ctx, typeNamespacedName, sealedage)
-			if err != nil && errors.IsNotFound(err) {
-				resource := &securityv1alpha1.SealedAge{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      resourceName,
-						Namespace: "default",
+			resource := &securityv1alpha1.SealedAge{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: "default",
+				},
+				Spec: securityv1alpha1.SealedAgeSpec{
+					EncryptedData: map[string]string{
+						"password": encryptArmored("s3cr3t", identity.Recipient()),
 					},
-					// TODO(user): Specify other spec details if needed.
-				}
-				Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+				},
 			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
 		})
 
 		AfterEach(func() {
-			// TODO(user): Cleanup logic after each test, like removing the resource instance.
 			resource := &securityv1alpha1.SealedAge{}
 			err := k8sClient.Get(ctx, typeNamespacedName, resource)
 			Expect(err).NotTo(HaveOccurred())
 
 			By("Cleanup the specific resource instance SealedAge")
+			if controllerutil.RemoveFinalizer(resource, securityv1alpha1.SecretFinalizer) {
+				Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			}
 			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			_ = k8sClient.Delete(ctx, keySecret)
+			_ = k8sClient.Delete(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: resourceName, Namespace: "default"}})
 		})
-		It("should successfully reconcile the resource", func() {
+
+		It("should decrypt the data and report Ready", func() {
+			Expect(k8sClient.Create(ctx, keySecret)).To(Succeed())
+
 			By("Reconciling the created resource")
-			controllerReconciler := &SealedAgeReconciler{
-				Client: k8sClient,
-				Scheme: k8sClient.Scheme(),
+			reconciler := newTestReconciler()
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drainEvents(reconciler)).To(ContainElements(
+				ContainSubstring(`Decrypted decrypted field "password" with key secret age-key-test`),
+				ContainSubstring("SecretCreated created Secret test-resource"),
+			))
+
+			By("not repeating the Decrypted event when the Secret is unchanged")
+			_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drainEvents(reconciler)).NotTo(ContainElement(ContainSubstring("Decrypted")))
+
+			Expect(testutil.ToFloat64(decryptsByKey.WithLabelValues("age-key-test"))).To(BeNumerically(">=", 1))
+
+			var secret corev1.Secret
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &secret)).To(Succeed())
+			Expect(secret.Data).To(HaveKeyWithValue("password", []byte("s3cr3t")))
+
+			var cr securityv1alpha1.SealedAge
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &cr)).To(Succeed())
+			for _, t := range []string{
+				securityv1alpha1.ConditionReady,
+				securityv1alpha1.ConditionKeysAvailable,
+				securityv1alpha1.ConditionDecrypted,
+				securityv1alpha1.ConditionSecretSynced,
+			} {
+				Expect(meta.IsStatusConditionTrue(cr.Status.Conditions, t)).To(BeTrue(), t)
+			}
+		})
+
+		DescribeTable("should prune fields removed from encryptedData",
+			func(policy securityv1alpha1.MergePolicy, keepsForeign bool) {
+				Expect(k8sClient.Create(ctx, keySecret)).To(Succeed())
+				reconciler := newTestReconciler()
+				_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+				Expect(err).NotTo(HaveOccurred())
+
+				By("adding a foreign key to the generated Secret")
+				var secret corev1.Secret
+				Expect(k8sClient.Get(ctx, typeNamespacedName, &secret)).To(Succeed())
+				secret.Data["foreign"] = []byte("kept?")
+				Expect(k8sClient.Update(ctx, &secret)).To(Succeed())
+
+				By("replacing the password field with a user field")
+				var cr securityv1alpha1.SealedAge
+				Expect(k8sClient.Get(ctx, typeNamespacedName, &cr)).To(Succeed())
+				cr.Spec.Template.MergePolicy = policy
+				cr.Spec.EncryptedData = map[string]string{"user": encryptArmored("admin", identity.Recipient())}
+				Expect(k8sClient.Update(ctx, &cr)).To(Succeed())
+
+				_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(k8sClient.Get(ctx, typeNamespacedName, &secret)).To(Succeed())
+				Expect(secret.Data).To(HaveKeyWithValue("user", []byte("admin")))
+				Expect(secret.Data).NotTo(HaveKey("password"))
+				if keepsForeign {
+					Expect(secret.Data).To(HaveKey("foreign"))
+				} else {
+					Expect(secret.Data).NotTo(HaveKey("foreign"))
+				}
+				Expect(secret.Annotations).To(HaveKeyWithValue(securityv1alpha1.ManagedFieldsAnnotation, "user"))
+			},
+			Entry("with Replace", securityv1alpha1.MergePolicyReplace, false),
+			Entry("with Merge", securityv1alpha1.MergePolicyMerge, true),
+		)
+
+		It("should apply the Secret template and recreate immutable Secrets", func() {
+			Expect(k8sClient.Create(ctx, keySecret)).To(Succeed())
+			immutable := true
+			secretKey := types.NamespacedName{Name: "templated", Namespace: "default"}
+			DeferCleanup(func() {
+				_ = k8sClient.Delete(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: secretKey.Name, Namespace: secretKey.Namespace}})
+			})
+
+			var cr securityv1alpha1.SealedAge
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &cr)).To(Succeed())
+			cr.Spec.Template.Immutable = &immutable
+			cr.Spec.Template.Metadata = securityv1alpha1.SealedAgeTemplateMetadata{
+				Name:        secretKey.Name,
+				Labels:      map[string]string{"argocd.argoproj.io/secret-type": "repository", "team": "a"},
+				Annotations: map[string]string{"owner": "platform"},
 			}
+			Expect(k8sClient.Update(ctx, &cr)).To(Succeed())
 
-			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+			reconciler := newTestReconciler()
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			var secret corev1.Secret
+			Expect(k8sClient.Get(ctx, secretKey, &secret)).To(Succeed())
+			Expect(secret.Labels).To(HaveKeyWithValue("argocd.argoproj.io/secret-type", "repository"))
+			Expect(secret.Annotations).To(HaveKeyWithValue("owner", "platform"))
+			Expect(secret.Immutable).To(HaveValue(BeTrue()))
+			firstUID := secret.UID
+
+			By("dropping a label and changing the ciphertext")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &cr)).To(Succeed())
+			delete(cr.Spec.Template.Metadata.Labels, "team")
+			cr.Spec.EncryptedData["password"] = encryptArmored("rotated", identity.Recipient())
+			Expect(k8sClient.Update(ctx, &cr)).To(Succeed())
+
+			_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, secretKey, &secret)).To(Succeed())
+			Expect(secret.UID).NotTo(Equal(firstUID))
+			Expect(secret.Data).To(HaveKeyWithValue("password", []byte("rotated")))
+			Expect(secret.Labels).NotTo(HaveKey("team"))
+			Expect(drainEvents(reconciler)).To(ContainElement(ContainSubstring("SecretRecreated")))
+		})
+
+		It("should not adopt an unowned Secret with adoptionPolicy Never", func() {
+			Expect(k8sClient.Create(ctx, keySecret)).To(Succeed())
+			Expect(k8sClient.Create(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: resourceName, Namespace: "default"},
+				Data:       map[string][]byte{"legacy": []byte("keep")},
+			})).To(Succeed())
+
+			var cr securityv1alpha1.SealedAge
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &cr)).To(Succeed())
+			cr.Spec.AdoptionPolicy = securityv1alpha1.AdoptionNever
+			Expect(k8sClient.Update(ctx, &cr)).To(Succeed())
+
+			reconciler := newTestReconciler()
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drainEvents(reconciler)).To(ContainElement(HavePrefix("Warning SecretConflict")))
+
+			var secret corev1.Secret
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &secret)).To(Succeed())
+			Expect(secret.Data).To(Equal(map[string][]byte{"legacy": []byte("keep")}))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &cr)).To(Succeed())
+			synced := meta.FindStatusCondition(cr.Status.Conditions, securityv1alpha1.ConditionSecretSynced)
+			Expect(synced).NotTo(BeNil())
+			Expect(synced.Reason).To(Equal(securityv1alpha1.ReasonSecretConflict))
+
+			By("switching to IfUnowned")
+			cr.Spec.AdoptionPolicy = securityv1alpha1.AdoptionIfUnowned
+			Expect(k8sClient.Update(ctx, &cr)).To(Succeed())
+			_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &secret)).To(Succeed())
+			Expect(secret.Labels).To(HaveKeyWithValue(securityv1alpha1.ManagedByLabel, securityv1alpha1.ManagedByValue))
+			Expect(metav1.IsControlledBy(&secret, &cr)).To(BeTrue())
+		})
+
+		It("should keep the Secret when deletionPolicy is Orphan", func() {
+			Expect(k8sClient.Create(ctx, keySecret)).To(Succeed())
+
+			var cr securityv1alpha1.SealedAge
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &cr)).To(Succeed())
+			cr.Spec.DeletionPolicy = securityv1alpha1.DeletionPolicyOrphan
+			Expect(k8sClient.Update(ctx, &cr)).To(Succeed())
+
+			reconciler := newTestReconciler()
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &cr)).To(Succeed())
+			Expect(cr.Finalizers).To(ContainElement(securityv1alpha1.SecretFinalizer))
+
+			By("deleting the SealedAge")
+			Expect(k8sClient.Delete(ctx, &cr)).To(Succeed())
+			_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, typeNamespacedName, &cr))).To(BeTrue())
+			var secret corev1.Secret
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &secret)).To(Succeed())
+			Expect(secret.OwnerReferences).To(BeEmpty())
+
+			// Recreate the SealedAge so AfterEach has something to clean up.
+			Expect(k8sClient.Create(ctx, &securityv1alpha1.SealedAge{
+				ObjectMeta: metav1.ObjectMeta{Name: resourceName, Namespace: "default"},
+				Spec:       securityv1alpha1.SealedAgeSpec{EncryptedData: map[string]string{"password": "unused"}},
+			})).To(Succeed())
+		})
+
+		It("should report NoKeysFound when no key Secret exists", func() {
+			reconciler := newTestReconciler()
+			result, err := reconciler.Reconcile(ctx, reconcile.Request{
 				NamespacedName: typeNamespacedName,
 			})
 			Expect(err).NotTo(HaveOccurred())
-			// TODO(user): Add more specific assertions depending on your controller's reconciliation logic.
-			// Example: If you expect a certain status condition after reconciliation, verify it here.
+			Expect(result.RequeueAfter).To(BeZero(), "the key Secret watch requeues, no polling")
+			Expect(drainEvents(reconciler)).To(ContainElement(HavePrefix("Warning WaitingForKeys")))
+
+			var cr securityv1alpha1.SealedAge
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &cr)).To(Succeed())
+			ready := meta.FindStatusCondition(cr.Status.Conditions, securityv1alpha1.ConditionReady)
+			Expect(ready).NotTo(BeNil())
+			Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+			Expect(ready.Reason).To(Equal(securityv1alpha1.ReasonNoKeysFound))
+			Expect(meta.FindStatusCondition(cr.Status.Conditions, securityv1alpha1.ConditionDecrypted).Status).
+				To(Equal(metav1.ConditionUnknown))
 		})
+
+		It("should decrypt passphrase fields without any key Secret", func() {
+			passphrase := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "bootstrap-passphrase", Namespace: testKeyNamespace},
+				Data:       map[string][]byte{"pw": []byte("correct horse\n")},
+			}
+			recipient, err := age.NewScryptRecipient("correct horse")
+			Expect(err).NotTo(HaveOccurred())
+			recipient.SetWorkFactor(10)
+
+			var cr securityv1alpha1.SealedAge
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &cr)).To(Succeed())
+			cr.Spec.EncryptedData = map[string]string{"password": encryptArmored("bootstrap", recipient)}
+			cr.Spec.PassphraseRefs = map[string]securityv1alpha1.PassphraseRef{
+				"password": {Name: passphrase.Name, Key: "pw"},
+			}
+			Expect(k8sClient.Update(ctx, &cr)).To(Succeed())
+
+			By("reconciling before the passphrase Secret exists")
+			reconciler := newTestReconciler()
+			_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).To(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &cr)).To(Succeed())
+			Expect(meta.FindStatusCondition(cr.Status.Conditions, securityv1alpha1.ConditionDecrypted).Reason).
+				To(Equal(securityv1alpha1.ReasonPassphraseMissing))
+
+			Expect(k8sClient.Create(ctx, passphrase)).To(Succeed())
+			DeferCleanup(func() { _ = k8sClient.Delete(ctx, passphrase) })
+			_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			var secret corev1.Secret
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &secret)).To(Succeed())
+			Expect(secret.Data).To(HaveKeyWithValue("password", []byte("bootstrap")))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &cr)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(cr.Status.Conditions, securityv1alpha1.ConditionReady)).To(BeTrue())
+			Expect(meta.FindStatusCondition(cr.Status.Conditions, securityv1alpha1.ConditionKeysAvailable).Reason).
+				To(Equal(securityv1alpha1.ReasonKeysNotRequired))
+		})
+
+		It("should map key Secret changes to SealedAges that are not Ready or use the key", func() {
+			reconciler := newTestReconciler()
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(reconciler.isKeySecret(keySecret)).To(BeTrue())
+			Expect(reconciler.isKeySecret(&corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+				Name: "app-secret", Namespace: testKeyNamespace,
+			}})).To(BeFalse())
+			Expect(reconciler.requestsForKeySecret(ctx, keySecret)).To(
+				ContainElement(reconcile.Request{NamespacedName: typeNamespacedName}))
+
+			By("creating the key and reconciling to Ready")
+			Expect(k8sClient.Create(ctx, keySecret)).To(Succeed())
+			_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			unrelated, _ := newKeySecret("age-key-unrelated")
+			Expect(reconciler.requestsForKeySecret(ctx, unrelated)).NotTo(
+				ContainElement(reconcile.Request{NamespacedName: typeNamespacedName}))
+			Expect(reconciler.requestsForKeySecret(ctx, keySecret)).To(
+				ContainElement(reconcile.Request{NamespacedName: typeNamespacedName}), "ready, but uses the key")
+		})
+
+		It("should flag retired keys and refuse revoked keys", func() {
+			Expect(k8sClient.Create(ctx, keySecret)).To(Succeed())
+			reconciler := newTestReconciler()
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			var cr securityv1alpha1.SealedAge
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &cr)).To(Succeed())
+			Expect(cr.Status.KeySecrets).To(Equal([]string{keySecret.Name}))
+			Expect(meta.IsStatusConditionTrue(cr.Status.Conditions, securityv1alpha1.ConditionKeysCurrent)).To(BeTrue())
+
+			By("retiring the key")
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(keySecret), keySecret)).To(Succeed())
+			keySecret.Annotations[securityv1alpha1.LegacyActiveAnnotation] = "false"
+			Expect(k8sClient.Update(ctx, keySecret)).To(Succeed())
+			Expect(reconciler.requestsForKeySecret(ctx, keySecret)).To(
+				ContainElement(reconcile.Request{NamespacedName: typeNamespacedName}))
+			_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &cr)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(cr.Status.Conditions, securityv1alpha1.ConditionReady)).To(BeTrue())
+			current := meta.FindStatusCondition(cr.Status.Conditions, securityv1alpha1.ConditionKeysCurrent)
+			Expect(current.Status).To(Equal(metav1.ConditionFalse))
+			Expect(current.Reason).To(Equal(securityv1alpha1.ReasonRetiredKeyInUse))
+			Expect(current.Message).To(ContainSubstring(keySecret.Name))
+
+			By("revoking the key")
+			keySecret.Annotations[securityv1alpha1.KeyStateAnnotation] = string(securityv1alpha1.KeyStateRevoked)
+			Expect(k8sClient.Update(ctx, keySecret)).To(Succeed())
+			_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drainEvents(reconciler)).To(ContainElement(HavePrefix("Warning KeyRevoked")))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &cr)).To(Succeed())
+			decrypted := meta.FindStatusCondition(cr.Status.Conditions, securityv1alpha1.ConditionDecrypted)
+			Expect(decrypted.Status).To(Equal(metav1.ConditionFalse))
+			Expect(decrypted.Reason).To(Equal(securityv1alpha1.ReasonKeyRevoked))
+			Expect(meta.IsStatusConditionFalse(cr.Status.Conditions, securityv1alpha1.ConditionReady)).To(BeTrue())
+		})
+
+		It("should reseal fields to the active keys and spec.recipients in place", func() {
+			By("retiring the key the data is sealed to and adding a new one")
+			keySecret.Annotations[securityv1alpha1.LegacyActiveAnnotation] = "false"
+			Expect(k8sClient.Create(ctx, keySecret)).To(Succeed())
+			newKey, newID := newKeySecret("age-key-new")
+			Expect(k8sClient.Create(ctx, newKey)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, newKey)).To(Succeed()) })
+			breakGlass := newIdentity()
+
+			var cr securityv1alpha1.SealedAge
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &cr)).To(Succeed())
+			cr.Spec.ResealPolicy = securityv1alpha1.ResealInPlace
+			cr.Spec.Recipients = []string{breakGlass.Recipient().String()}
+			Expect(k8sClient.Update(ctx, &cr)).To(Succeed())
+
+			reconciler := newTestReconciler()
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drainEvents(reconciler)).To(ContainElement(ContainSubstring(`Resealed resealed field(s) password`)))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &cr)).To(Succeed())
+			resealed := cr.Spec.EncryptedData["password"]
+			for id, want := range map[age.Identity]bool{identity: false, newID: true, breakGlass: true} {
+				ring := &agecrypt.Keyring{}
+				ring.Add("key", id)
+				plain, _, err := ring.Decrypt(resealed)
+				if !want {
+					Expect(err).To(HaveOccurred())
+					continue
+				}
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(plain)).To(Equal("s3cr3t"))
+			}
+			Expect(cr.Status.SealedRecipients).To(ConsistOf(newID.Recipient().String(), breakGlass.Recipient().String()))
+			Expect(cr.Status.ObservedGeneration).To(Equal(cr.Generation))
+			for _, c := range cr.Status.Conditions {
+				Expect(c.ObservedGeneration).To(Equal(cr.Generation), c.Type)
+			}
+			Expect(strings.Split(cr.Annotations[securityv1alpha1.SealedRecipientsAnnotation], ",")).
+				To(ConsistOf(newID.Recipient().String(), breakGlass.Recipient().String()))
+
+			By("leaving current fields alone")
+			_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &cr)).To(Succeed())
+			Expect(cr.Spec.EncryptedData["password"]).To(Equal(resealed))
+			Expect(cr.Status.KeySecrets).To(Equal([]string{newKey.Name}))
+			Expect(meta.IsStatusConditionTrue(cr.Status.Conditions, securityv1alpha1.ConditionKeysCurrent)).To(BeTrue())
+		})
+
+		It("should export a resealed manifest until it is applied", func() {
+			keySecret.Annotations[securityv1alpha1.LegacyActiveAnnotation] = "false"
+			Expect(k8sClient.Create(ctx, keySecret)).To(Succeed())
+			newKey, newID := newKeySecret("age-key-new")
+			Expect(k8sClient.Create(ctx, newKey)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, newKey)).To(Succeed()) })
+
+			var cr securityv1alpha1.SealedAge
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &cr)).To(Succeed())
+			cr.Spec.ResealPolicy = securityv1alpha1.ResealExport
+			Expect(k8sClient.Update(ctx, &cr)).To(Succeed())
+			original := cr.Spec.EncryptedData["password"]
+
+			reconciler := newTestReconciler()
+			_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			exportName := types.NamespacedName{Name: resourceName + "-resealed", Namespace: "default"}
+			var cm corev1.ConfigMap
+			Expect(k8sClient.Get(ctx, exportName, &cm)).To(Succeed())
+			Expect(cm.Data["sealedage.yaml"]).NotTo(ContainSubstring("status"))
+			var exported securityv1alpha1.SealedAge
+			Expect(yaml.Unmarshal([]byte(cm.Data["sealedage.yaml"]), &exported)).To(Succeed())
+			Expect(exported.Kind).To(Equal("SealedAge"))
+			Expect(exported.Annotations).To(HaveKeyWithValue(securityv1alpha1.SealedRecipientsAnnotation,
+				newID.Recipient().String()), "recorded for sealage edit")
+			ring := &agecrypt.Keyring{}
+			ring.Add("key", newID)
+			_, _, err = ring.Decrypt(exported.Spec.EncryptedData["password"])
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &cr)).To(Succeed())
+			Expect(cr.Spec.EncryptedData["password"]).To(Equal(original), "spec is left to git")
+
+			By("keeping the export stable")
+			_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			var again corev1.ConfigMap
+			Expect(k8sClient.Get(ctx, exportName, &again)).To(Succeed())
+			Expect(again.Data).To(Equal(cm.Data))
+
+			By("applying the exported manifest")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &cr)).To(Succeed())
+			cr.Spec = exported.Spec
+			Expect(k8sClient.Update(ctx, &cr)).To(Succeed())
+			_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			err = k8sClient.Get(ctx, exportName, &again)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &cr)).To(Succeed())
+			Expect(cr.Status.SealedRecipients).To(Equal([]string{newID.Recipient().String()}))
+		})
+
+		It("should report a ConfigMap in the way of the export instead of retrying", func() {
+			keySecret.Annotations[securityv1alpha1.LegacyActiveAnnotation] = "false"
+			Expect(k8sClient.Create(ctx, keySecret)).To(Succeed())
+			newKey, _ := newKeySecret("age-key-new")
+			Expect(k8sClient.Create(ctx, newKey)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, newKey)).To(Succeed()) })
+			users := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: resourceName + "-resealed", Namespace: "default"},
+				Data:       map[string]string{"owner": "someone else"},
+			}
+			Expect(k8sClient.Create(ctx, users)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, users)).To(Succeed()) })
+
+			var cr securityv1alpha1.SealedAge
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &cr)).To(Succeed())
+			cr.Spec.ResealPolicy = securityv1alpha1.ResealExport
+			Expect(k8sClient.Update(ctx, &cr)).To(Succeed())
+
+			reconciler := newTestReconciler()
+			result, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(conflictRequeueAfter))
+			Expect(drainEvents(reconciler)).To(ContainElement(ContainSubstring("ExportConflict ConfigMap " + users.Name)))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &cr)).To(Succeed())
+			resealed := meta.FindStatusCondition(cr.Status.Conditions, securityv1alpha1.ConditionResealed)
+			Expect(resealed).NotTo(BeNil())
+			Expect(resealed.Status).To(Equal(metav1.ConditionFalse))
+			Expect(resealed.Reason).To(Equal(securityv1alpha1.ReasonExportConflict))
+			Expect(meta.IsStatusConditionTrue(cr.Status.Conditions, securityv1alpha1.ConditionReady)).To(BeTrue())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(users), users)).To(Succeed())
+			Expect(users.Data).To(Equal(map[string]string{"owner": "someone else"}))
+			Expect(users.OwnerReferences).To(BeEmpty())
+		})
+
+		It("should report DecryptFailed when no key matches", func() {
+			other, _ := newKeySecret("age-key-other")
+			keySecret = other
+			Expect(k8sClient.Create(ctx, keySecret)).To(Succeed())
+
+			_, err := newTestReconciler().Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).To(HaveOccurred())
+
+			var cr securityv1alpha1.SealedAge
+			Expect(k8sClient.Get(ctx, typeNamespacedName, &cr)).To(Succeed())
+			decrypted := meta.FindStatusCondition(cr.Status.Conditions, securityv1alpha1.ConditionDecrypted)
+			Expect(decrypted).NotTo(BeNil())
+			Expect(decrypted.Reason).To(Equal(securityv1alpha1.ReasonDecryptFailed))
+			Expect(meta.IsStatusConditionFalse(cr.Status.Conditions, securityv1alpha1.ConditionReady)).To(BeTrue())
+		})
+	})
+
+	It("keeps the readiness gauge current from informer events alone", func() {
+		ready := func(status metav1.ConditionStatus) float64 {
+			return testutil.ToFloat64(sealedAgesByReady.WithLabelValues(string(status)))
+		}
+		handler := readyHandler()
+		cr := &securityv1alpha1.SealedAge{}
+		cr.Name = "readiness"
+		cr.Namespace = "default"
+
+		By("adding a SealedAge that was never reconciled, as on a standby replica")
+		handler.OnAdd(cr, false)
+		Expect(ready(metav1.ConditionUnknown)).To(Equal(1.0))
+
+		By("seeing the leader mark it Ready")
+		reconciled := cr.DeepCopy()
+		meta.SetStatusCondition(&reconciled.Status.Conditions, metav1.Condition{
+			Type:   securityv1alpha1.ConditionReady,
+			Status: metav1.ConditionTrue,
+			Reason: securityv1alpha1.ReasonSucceeded,
+		})
+		handler.OnUpdate(cr, reconciled)
+		Expect(ready(metav1.ConditionUnknown)).To(BeZero())
+		Expect(ready(metav1.ConditionTrue)).To(Equal(1.0))
+
+		By("deleting it")
+		handler.OnDelete(toolscache.DeletedFinalStateUnknown{Key: "default/readiness", Obj: reconciled})
+		Expect(ready(metav1.ConditionTrue)).To(BeZero())
 	})
 })
@@ -0,0 +1,174 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+)
+
+// applySecretData writes the decrypted fields into secret.Data according to the
+// merge policy and records the written keys in the managed-fields annotation.
+//
+//   - Replace (default): Data becomes exactly the decrypted fields.
+//   - Merge: foreign keys are kept, keys listed in the previous annotation but
+//     no longer decrypted are removed.
+func applySecretData(secret *corev1.Secret, plain map[string][]byte, policy securityv1alpha1.MergePolicy) {
+	if policy == securityv1alpha1.MergePolicyMerge {
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		for _, k := range managedFields(secret) {
+			if _, ok := plain[k]; !ok {
+				delete(secret.Data, k)
+			}
+		}
+	} else {
+		secret.Data = map[string][]byte{}
+	}
+	for k, v := range plain {
+		secret.Data[k] = v
+	}
+
+	setManagedKeys(secret, securityv1alpha1.ManagedFieldsAnnotation, plain)
+}
+
+// managedFields returns the data keys previously written by the SealedAge.
+func managedFields(secret *corev1.Secret) []string {
+	return managedKeys(secret, securityv1alpha1.ManagedFieldsAnnotation)
+}
+
+// secretNameFor returns the name of the Secret generated for cr.
+func secretNameFor(cr *securityv1alpha1.SealedAge) string {
+	if n := cr.Spec.Template.Metadata.Name; n != "" {
+		return n
+	}
+	return cr.Name
+}
+
+// applySecretTemplate syncs type, immutable flag, labels and annotations from
+// the template. Labels/annotations removed from the template are removed from
+// the Secret; those added by others are left alone.
+func applySecretTemplate(secret *corev1.Secret, tmpl securityv1alpha1.SealedAgeTemplate) {
+	if tmpl.Type != "" {
+		secret.Type = corev1.SecretType(tmpl.Type)
+	} else {
+		secret.Type = corev1.SecretTypeOpaque
+	}
+	secret.Immutable = tmpl.Immutable
+
+	secret.Labels = syncMap(secret.Labels, tmpl.Metadata.Labels,
+		managedKeys(secret, securityv1alpha1.ManagedLabelsAnnotation))
+	secret.Annotations = syncMap(secret.Annotations, tmpl.Metadata.Annotations,
+		managedKeys(secret, securityv1alpha1.ManagedAnnotationsAnnotation))
+	setManagedKeys(secret, securityv1alpha1.ManagedLabelsAnnotation, tmpl.Metadata.Labels)
+	setManagedKeys(secret, securityv1alpha1.ManagedAnnotationsAnnotation, tmpl.Metadata.Annotations)
+
+	if secret.Labels == nil {
+		secret.Labels = map[string]string{}
+	}
+	secret.Labels[securityv1alpha1.ManagedByLabel] = securityv1alpha1.ManagedByValue
+}
+
+// adoptionConflict returns why cr may not take over existing under its
+// adoption policy, or "" when it may.
+func adoptionConflict(cr *securityv1alpha1.SealedAge, existing *corev1.Secret) string {
+	owner := metav1.GetControllerOf(existing)
+	switch {
+	case owner != nil && owner.UID == cr.UID:
+		return ""
+	case owner != nil && cr.Spec.AdoptionPolicy != securityv1alpha1.AdoptionAlways:
+		return fmt.Sprintf("Secret %s is controlled by %s %s (adoptionPolicy %s)",
+			existing.Name, owner.Kind, owner.Name, adoptionPolicy(cr))
+	case owner == nil && cr.Spec.AdoptionPolicy == securityv1alpha1.AdoptionNever:
+		return fmt.Sprintf("Secret %s already exists and is not managed by this SealedAge (adoptionPolicy Never)",
+			existing.Name)
+	}
+	return ""
+}
+
+// adoptionPolicy returns the effective policy, IfUnowned when unset.
+func adoptionPolicy(cr *securityv1alpha1.SealedAge) securityv1alpha1.AdoptionPolicy {
+	if cr.Spec.AdoptionPolicy == "" {
+		return securityv1alpha1.AdoptionIfUnowned
+	}
+	return cr.Spec.AdoptionPolicy
+}
+
+// releaseForeignController drops controller owner references of other owners,
+// so cr can become the controller of an adopted Secret.
+func releaseForeignController(cr *securityv1alpha1.SealedAge, secret *corev1.Secret) {
+	refs := secret.OwnerReferences[:0]
+	for _, ref := range secret.OwnerReferences {
+		if ref.Controller != nil && *ref.Controller && ref.UID != cr.UID {
+			continue
+		}
+		refs = append(refs, ref)
+	}
+	secret.OwnerReferences = refs
+}
+
+// needsRecreate reports whether existing cannot be updated in place to desired:
+// the type of a Secret never changes, and immutable Secrets keep data and flag.
+func needsRecreate(existing, desired *corev1.Secret) bool {
+	if existing.Type != desired.Type {
+		return true
+	}
+	if existing.Immutable == nil || !*existing.Immutable {
+		return false
+	}
+	return !equality.Semantic.DeepEqual(existing.Data, desired.Data) ||
+		!equality.Semantic.DeepEqual(existing.Immutable, desired.Immutable)
+}
+
+// syncMap removes previously managed keys missing from desired and sets desired.
+func syncMap(dst, desired map[string]string, previous []string) map[string]string {
+	for _, k := range previous {
+		if _, ok := desired[k]; !ok {
+			delete(dst, k)
+		}
+	}
+	if len(desired) > 0 && dst == nil {
+		dst = map[string]string{}
+	}
+	for k, v := range desired {
+		dst[k] = v
+	}
+	return dst
+}
+
+// managedKeys parses a comma-separated key list annotation.
+func managedKeys(secret *corev1.Secret, annotation string) []string {
+	v := secret.Annotations[annotation]
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, ",")
+}
+
+// setManagedKeys records the sorted keys of m in annotation, or drops it when m is empty.
+func setManagedKeys[V any](secret *corev1.Secret, annotation string, m map[string]V) {
+	if len(m) == 0 {
+		delete(secret.Annotations, annotation)
+		return
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[annotation] = strings.Join(keys, ",")
+}
@@ -0,0 +1,248 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+package sealage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	age "filippo.io/age"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+	"github.com/callmewhatuwant/sealed-age-operator/internal/agecrypt"
+)
+
+// ReadSealedAge reads a manifest holding exactly one SealedAge.
+func ReadSealedAge(r io.Reader) (*securityv1alpha1.SealedAge, error) {
+	dec := utilyaml.NewYAMLOrJSONDecoder(r, 4096)
+	var found *securityv1alpha1.SealedAge
+	for n := 1; ; n++ {
+		var sa securityv1alpha1.SealedAge
+		if err := dec.Decode(&sa); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("document %d: %w", n, err)
+		}
+		if sa.APIVersion == "" && sa.Kind == "" && sa.Name == "" {
+			continue
+		}
+		if sa.APIVersion != securityv1alpha1.GroupVersion.String() || sa.Kind != "SealedAge" {
+			return nil, fmt.Errorf("document %d: expected a SealedAge, got %s %s", n, sa.APIVersion, sa.Kind)
+		}
+		if found != nil {
+			return nil, errors.New("more than one SealedAge found")
+		}
+		found = &sa
+	}
+	if found == nil {
+		return nil, errors.New("no SealedAge found")
+	}
+	return found, nil
+}
+
+// ReadIdentityFile reads an age identity file or an unencrypted ssh private key.
+func ReadIdentityFile(path string) ([]age.Identity, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if agecrypt.IsSSHPrivateKey(b) {
+		id, err := agecrypt.ParseSSHIdentity(b, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return []age.Identity{id}, nil
+	}
+	ids, err := agecrypt.ParseIdentities(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return ids, nil
+}
+
+// ErrNoSealedRecipients is returned by RecipientsOf for a SealedAge without
+// the SealedRecipientsAnnotation. age doesn't record X25519 recipients in the
+// ciphertext, so they can't be recovered from encryptedData.
+var ErrNoSealedRecipients = errors.New("the SealedAge doesn't record the recipients it is sealed to")
+
+// RecipientsOf returns the recipients sa is sealed to, as recorded in the
+// SealedRecipientsAnnotation, plus spec.recipients. Without the annotation it
+// fails with ErrNoSealedRecipients rather than leave out the cluster keys.
+func RecipientsOf(sa *securityv1alpha1.SealedAge) ([]string, error) {
+	var recipients []string
+	for _, s := range strings.Split(sa.Annotations[securityv1alpha1.SealedRecipientsAnnotation], ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			recipients = append(recipients, s)
+		}
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("%w (no %s annotation)", ErrNoSealedRecipients, securityv1alpha1.SealedRecipientsAnnotation)
+	}
+	return append(recipients, sa.Spec.Recipients...), nil
+}
+
+// Unseal decrypts sa with ring into the Secret the operator would generate.
+// Fields with a passphraseRef are left out. UTF-8 values go to stringData so
+// they can be edited as text, everything else to data.
+func Unseal(sa *securityv1alpha1.SealedAge, ring *agecrypt.Keyring) (*corev1.Secret, error) {
+	tmpl := sa.Spec.Template
+	name := tmpl.Metadata.Name
+	if name == "" {
+		name = sa.Name
+	}
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   sa.Namespace,
+			Labels:      maps.Clone(tmpl.Metadata.Labels),
+			Annotations: maps.Clone(tmpl.Metadata.Annotations),
+		},
+		Type:      corev1.SecretType(tmpl.Type),
+		Immutable: tmpl.Immutable,
+	}
+	for _, field := range slices.Sorted(maps.Keys(sa.Spec.EncryptedData)) {
+		if _, ok := sa.Spec.PassphraseRefs[field]; ok {
+			continue
+		}
+		plain, _, err := ring.Decrypt(sa.Spec.EncryptedData[field])
+		if err != nil {
+			return nil, fmt.Errorf("decrypt %s: %w", field, err)
+		}
+		if utf8.Valid(plain) {
+			if secret.StringData == nil {
+				secret.StringData = map[string]string{}
+			}
+			secret.StringData[field] = string(plain)
+			continue
+		}
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		secret.Data[field] = plain
+	}
+	return secret, nil
+}
+
+// MarshalSecret renders a Secret manifest for editing.
+func MarshalSecret(secret *corev1.Secret) ([]byte, error) {
+	b, err := json.Marshal(secret)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	if md, ok := doc["metadata"].(map[string]interface{}); ok {
+		delete(md, "creationTimestamp")
+	}
+	return yaml.Marshal(doc)
+}
+
+// Reseal applies the edit of the Secret before (as returned by Unseal) into
+// after to sa. Changed and added fields are sealed to recipients, unchanged
+// fields keep their exact ciphertext and removed fields are dropped. When
+// recipients differ from the ones sa records, every field is resealed and the
+// SealedRecipientsAnnotation rewritten, so the fields never mix recipient
+// sets. Edited type, labels, annotations and immutable go to the template. It
+// returns a description of every change.
+func Reseal(sa *securityv1alpha1.SealedAge, before, after *corev1.Secret, recipients []string) ([]string, error) {
+	if after.Name != before.Name || after.Namespace != before.Namespace {
+		return nil, errors.New("the Secret name and namespace can't be changed")
+	}
+	names, parsed, err := ParseRecipients(recipients)
+	if err != nil {
+		return nil, err
+	}
+	resealAll := len(names) > 0 && !slices.Equal(names, sealedTo(sa))
+
+	seal := func(field string, plain []byte) error {
+		if len(parsed) == 0 {
+			return errors.New("no recipients to seal changed fields to")
+		}
+		enc, err := agecrypt.Encrypt(plain, parsed...)
+		if err != nil {
+			return fmt.Errorf("encrypt %s: %w", field, err)
+		}
+		sa.Spec.EncryptedData[field] = enc
+		return nil
+	}
+
+	var changes []string
+	old, edited := secretData(before), secretData(after)
+	for _, field := range slices.Sorted(maps.Keys(edited)) {
+		if _, ok := sa.Spec.PassphraseRefs[field]; ok {
+			return nil, fmt.Errorf("field %s uses a passphraseRef and can't be edited", field)
+		}
+		prev, ok := old[field]
+		switch {
+		case ok && bytes.Equal(prev, edited[field]) && !resealAll:
+			continue
+		case ok && bytes.Equal(prev, edited[field]):
+			changes = append(changes, "resealed field "+field)
+		case ok:
+			changes = append(changes, "updated field "+field)
+		default:
+			changes = append(changes, "added field "+field)
+		}
+		if sa.Spec.EncryptedData == nil {
+			sa.Spec.EncryptedData = map[string]string{}
+		}
+		if err := seal(field, edited[field]); err != nil {
+			return nil, err
+		}
+	}
+	for _, field := range slices.Sorted(maps.Keys(old)) {
+		if _, ok := edited[field]; !ok {
+			delete(sa.Spec.EncryptedData, field)
+			changes = append(changes, "removed field "+field)
+		}
+	}
+
+	if resealAll {
+		if sa.Annotations == nil {
+			sa.Annotations = map[string]string{}
+		}
+		sa.Annotations[securityv1alpha1.SealedRecipientsAnnotation] = strings.Join(names, ",")
+		changes = append(changes, "updated recipients")
+	}
+
+	if tmpl := templateOf(after); !reflect.DeepEqual(tmpl, templateOf(before)) {
+		tmpl.Metadata.Name = sa.Spec.Template.Metadata.Name
+		tmpl.MergePolicy = sa.Spec.Template.MergePolicy
+		sa.Spec.Template = tmpl
+		changes = append(changes, "updated template")
+	}
+	return changes, nil
+}
+
+// sealedTo returns the recipients sa records, as normalized by
+// ParseRecipients, or nil when they are missing or don't parse.
+func sealedTo(sa *securityv1alpha1.SealedAge) []string {
+	recorded, err := RecipientsOf(sa)
+	if err != nil {
+		return nil
+	}
+	names, _, err := ParseRecipients(recorded)
+	if err != nil {
+		return nil
+	}
+	return names
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sealage
+
+import (
+	"bytes"
+
+	age "filippo.io/age"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+	"github.com/callmewhatuwant/sealed-age-operator/internal/agecrypt"
+)
+
+var _ = Describe("Edit", func() {
+	var (
+		admin, operator *age.X25519Identity
+		ring            *agecrypt.Keyring
+		sa              *securityv1alpha1.SealedAge
+	)
+
+	BeforeEach(func() {
+		var err error
+		admin, err = age.GenerateX25519Identity()
+		Expect(err).NotTo(HaveOccurred())
+		operator, err = age.GenerateX25519Identity()
+		Expect(err).NotTo(HaveOccurred())
+		ring = &agecrypt.Keyring{}
+		ring.Add("admin", admin)
+
+		sa, err = Seal(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "team", Labels: map[string]string{"app": "db"}},
+			Data:       map[string][]byte{"cert": {0xff, 0x00}},
+			StringData: map[string]string{"username": "admin", "password": "hunter2"},
+		}, admin.Recipient().String(), operator.Recipient().String())
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("decrypts into an editable Secret and reads it back", func() {
+		secret, err := Unseal(sa, ring)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(secret.StringData).To(Equal(map[string]string{"username": "admin", "password": "hunter2"}))
+		Expect(secret.Data).To(Equal(map[string][]byte{"cert": {0xff, 0x00}}), "binary values stay base64")
+		Expect(secret.Labels).To(Equal(map[string]string{"app": "db"}))
+
+		out, err := MarshalSecret(secret)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).NotTo(ContainSubstring("creationTimestamp"))
+		back, err := ReadSecrets(bytes.NewReader(out))
+		Expect(err).NotTo(HaveOccurred())
+		changes, err := Reseal(sa.DeepCopy(), secret, &back[0], nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changes).To(BeEmpty())
+	})
+
+	It("reseals only changed fields to the recorded recipients", func() {
+		recipients, err := RecipientsOf(sa)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recipients).To(ConsistOf(admin.Recipient().String(), operator.Recipient().String()))
+		original := sa.DeepCopy()
+		before, err := Unseal(sa, ring)
+		Expect(err).NotTo(HaveOccurred())
+
+		after := before.DeepCopy()
+		after.StringData["password"] = "hunter3"
+		after.StringData["host"] = "db.team"
+		delete(after.StringData, "username")
+		after.Labels["tier"] = "backend"
+		changes, err := Reseal(sa, before, after, recipients)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changes).To(Equal([]string{
+			"added field host", "updated field password", "removed field username", "updated template",
+		}))
+
+		Expect(sa.Spec.EncryptedData["cert"]).To(Equal(original.Spec.EncryptedData["cert"]), "unchanged ciphertext is kept")
+		Expect(sa.Spec.EncryptedData).NotTo(HaveKey("username"))
+		Expect(sa.Spec.Template.Metadata.Labels).To(HaveKeyWithValue("tier", "backend"))
+		opRing := &agecrypt.Keyring{}
+		opRing.Add("operator", operator)
+		for field, want := range map[string]string{"password": "hunter3", "host": "db.team"} {
+			plain, _, err := opRing.Decrypt(sa.Spec.EncryptedData[field])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(plain)).To(Equal(want))
+		}
+	})
+
+	It("reseals every field when the recipients change", func() {
+		rotated, err := age.GenerateX25519Identity()
+		Expect(err).NotTo(HaveOccurred())
+		recipients := []string{admin.Recipient().String(), rotated.Recipient().String()}
+		before, err := Unseal(sa, ring)
+		Expect(err).NotTo(HaveOccurred())
+
+		after := before.DeepCopy()
+		after.StringData["password"] = "hunter3"
+		changes, err := Reseal(sa, before, after, recipients)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changes).To(Equal([]string{
+			"resealed field cert", "updated field password", "resealed field username", "updated recipients",
+		}))
+		recorded, err := RecipientsOf(sa)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recorded).To(ConsistOf(recipients))
+
+		rotatedRing, operatorRing := &agecrypt.Keyring{}, &agecrypt.Keyring{}
+		rotatedRing.Add("rotated", rotated)
+		operatorRing.Add("operator", operator)
+		for field, enc := range sa.Spec.EncryptedData {
+			stanzas, err := agecrypt.ParseHeader(enc)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stanzas).To(HaveLen(2), "field %s", field)
+			_, err = rotatedRing.Match(stanzas)
+			Expect(err).NotTo(HaveOccurred(), "field %s is sealed to the new key", field)
+			_, err = operatorRing.Match(stanzas)
+			Expect(err).To(HaveOccurred(), "field %s is no longer sealed to the old key", field)
+		}
+
+		By("keeping the ciphertext on the next edit with the recorded recipients")
+		resealed := sa.DeepCopy()
+		before, err = Unseal(sa, ring)
+		Expect(err).NotTo(HaveOccurred())
+		changes, err = Reseal(sa, before, before.DeepCopy(), recorded)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changes).To(BeEmpty())
+		Expect(sa).To(Equal(resealed))
+	})
+
+	It("refuses renames, passphrase fields and unknown recipients", func() {
+		sa.Spec.PassphraseRefs = map[string]securityv1alpha1.PassphraseRef{"cert": {Name: "bootstrap"}}
+		recipients, err := RecipientsOf(sa)
+		Expect(err).NotTo(HaveOccurred())
+		before, err := Unseal(sa, ring)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(before.Data).NotTo(HaveKey("cert"), "passphrase fields are not decrypted")
+
+		renamed := before.DeepCopy()
+		renamed.Name = "other"
+		_, err = Reseal(sa.DeepCopy(), before, renamed, recipients)
+		Expect(err).To(MatchError(ContainSubstring("can't be changed")))
+
+		passphrase := before.DeepCopy()
+		passphrase.StringData["cert"] = "x"
+		_, err = Reseal(sa.DeepCopy(), before, passphrase, recipients)
+		Expect(err).To(MatchError(ContainSubstring("passphraseRef")))
+
+		changed := before.DeepCopy()
+		changed.StringData["password"] = "hunter3"
+		_, err = Reseal(sa.DeepCopy(), before, changed, nil)
+		Expect(err).To(MatchError(ContainSubstring("no recipients")))
+	})
+
+	It("refuses to guess the recipients without the annotation", func() {
+		sa.Spec.Recipients = []string{admin.Recipient().String()}
+		delete(sa.Annotations, securityv1alpha1.SealedRecipientsAnnotation)
+		_, err := RecipientsOf(sa)
+		Expect(err).To(MatchError(ErrNoSealedRecipients))
+	})
+})
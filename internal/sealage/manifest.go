@@ -0,0 +1,101 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+package sealage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"sigs.k8s.io/yaml"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+)
+
+// Format is an output format for manifests.
+type Format string
+
+const (
+	FormatYAML Format = "yaml"
+	FormatJSON Format = "json"
+)
+
+// ParseFormat validates an -o flag value.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatYAML, FormatJSON:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown output format %q (yaml or json)", s)
+	}
+}
+
+// Marshal renders SealedAges as manifests: YAML documents separated by ---,
+// or JSON (a v1 List when there is more than one).
+func Marshal(objs []securityv1alpha1.SealedAge, format Format) ([]byte, error) {
+	docs := make([]map[string]interface{}, 0, len(objs))
+	for i := range objs {
+		doc, err := manifestOf(&objs[i])
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+
+	if format == FormatJSON {
+		var v interface{} = docs
+		if len(docs) == 1 {
+			v = docs[0]
+		} else {
+			v = map[string]interface{}{"apiVersion": "v1", "kind": "List", "items": docs}
+		}
+		out, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(out, '\n'), nil
+	}
+
+	var buf bytes.Buffer
+	for i, doc := range docs {
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		out, err := yaml.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(out)
+	}
+	return buf.Bytes(), nil
+}
+
+// manifestOf converts obj to a generic manifest without the fields that don't
+// belong in git: the empty status, creationTimestamp and an empty template.
+func manifestOf(obj *securityv1alpha1.SealedAge) (map[string]interface{}, error) {
+	b, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	delete(doc, "status")
+	if md, ok := doc["metadata"].(map[string]interface{}); ok {
+		delete(md, "creationTimestamp")
+	}
+	if spec, ok := doc["spec"].(map[string]interface{}); ok {
+		tmpl, _ := spec["template"].(map[string]interface{})
+		if md, ok := tmpl["metadata"].(map[string]interface{}); ok && len(md) == 0 {
+			delete(tmpl, "metadata")
+		}
+		if len(tmpl) == 0 {
+			delete(spec, "template")
+		}
+	}
+	return doc, nil
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+package sealage
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	age "filippo.io/age"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+	"github.com/callmewhatuwant/sealed-age-operator/internal/agecrypt"
+)
+
+// maxRecipientsSize caps a recipients file fetched over HTTP.
+const maxRecipientsSize = 1 << 20
+
+// ReadRecipients reads a recipients file as accepted by `age -R` and returns
+// its recipients, each checked with agecrypt.ParseRecipient. Unlike
+// agecrypt.ParseRecipients it keeps their string form, which SealedAges record.
+func ReadRecipients(r io.Reader) ([]string, error) {
+	var recipients []string
+	scanner := bufio.NewScanner(r)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, err := agecrypt.ParseRecipient(line); err != nil {
+			return nil, fmt.Errorf("error at line %d: %w", n, err)
+		}
+		recipients = append(recipients, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recipients: %w", err)
+	}
+	if len(recipients) == 0 {
+		return nil, errors.New("no recipients found")
+	}
+	return recipients, nil
+}
+
+// ParseRecipients parses recipient strings, dropping duplicates and the
+// comment of ssh keys. It returns the sorted strings along with the parsed
+// recipients.
+func ParseRecipients(recipients []string) ([]string, []age.Recipient, error) {
+	names := make([]string, 0, len(recipients))
+	for _, s := range recipients {
+		names = append(names, normalizeRecipient(s))
+	}
+	slices.Sort(names)
+	names = slices.Compact(names)
+	parsed := make([]age.Recipient, 0, len(names))
+	for _, s := range names {
+		r, err := agecrypt.ParseRecipient(s)
+		if err != nil {
+			return nil, nil, err
+		}
+		parsed = append(parsed, r)
+	}
+	return names, parsed, nil
+}
+
+// normalizeRecipient trims a recipient string and drops the comment of ssh keys.
+func normalizeRecipient(s string) string {
+	if f := strings.Fields(s); len(f) > 2 && strings.HasPrefix(s, "ssh-") {
+		s = f[0] + " " + f[1]
+	}
+	return strings.TrimSpace(s)
+}
+
+// RecipientsFromFile reads a recipients file as accepted by `age -R`.
+func RecipientsFromFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	recipients, err := ReadRecipients(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return recipients, nil
+}
+
+// RecipientsFromURL fetches the operator's recipients endpoint (/recipients).
+func RecipientsFromURL(ctx context.Context, url string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/plain")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	recipients, err := ReadRecipients(io.LimitReader(resp.Body, maxRecipientsSize))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	return recipients, nil
+}
+
+// RecipientsFromConfigMap reads the recipients ConfigMap the operator
+// publishes in each namespace (--recipients-configmap).
+func RecipientsFromConfigMap(ctx context.Context, c client.Reader, key types.NamespacedName) ([]string, error) {
+	var cm corev1.ConfigMap
+	if err := c.Get(ctx, key, &cm); err != nil {
+		return nil, err
+	}
+	recipients, err := ReadRecipients(strings.NewReader(cm.Data[securityv1alpha1.RecipientsConfigMapKey]))
+	if err != nil {
+		return nil, fmt.Errorf("configmap %s: %w", key, err)
+	}
+	return recipients, nil
+}
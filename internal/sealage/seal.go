@@ -0,0 +1,132 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+// Package sealage implements the sealage CLI: sealing Secret manifests into
+// SealedAge manifests.
+package sealage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+	"github.com/callmewhatuwant/sealed-age-operator/internal/agecrypt"
+)
+
+// lastAppliedAnnotation holds the whole object, plaintext data included, so it
+// is never carried over into the template.
+const lastAppliedAnnotation = corev1.LastAppliedConfigAnnotation
+
+// ReadSecrets reads one or more v1 Secret manifests (YAML documents separated
+// by ---, or JSON) from r. Empty documents are skipped.
+func ReadSecrets(r io.Reader) ([]corev1.Secret, error) {
+	dec := utilyaml.NewYAMLOrJSONDecoder(r, 4096)
+	var secrets []corev1.Secret
+	for n := 1; ; n++ {
+		var s corev1.Secret
+		if err := dec.Decode(&s); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("document %d: %w", n, err)
+		}
+		if s.APIVersion == "" && s.Kind == "" && s.Name == "" {
+			continue
+		}
+		if s.APIVersion != "v1" || s.Kind != "Secret" {
+			return nil, fmt.Errorf("document %d: expected a v1 Secret, got %s %s", n, s.APIVersion, s.Kind)
+		}
+		secrets = append(secrets, s)
+	}
+	if len(secrets) == 0 {
+		return nil, errors.New("no Secret found")
+	}
+	return secrets, nil
+}
+
+// Seal encrypts every data and stringData value of secret to recipients and
+// returns the matching SealedAge. stringData wins over data, as in the API
+// server. Type, labels, annotations and immutable go into the template, the
+// recipients into the SealedRecipientsAnnotation.
+func Seal(secret *corev1.Secret, recipients ...string) (*securityv1alpha1.SealedAge, error) {
+	if secret.Name == "" {
+		return nil, errors.New("secret has no name")
+	}
+	names, parsed, err := ParseRecipients(recipients)
+	if err != nil {
+		return nil, err
+	}
+	if len(parsed) == 0 {
+		return nil, errors.New("no recipients")
+	}
+
+	plain := secretData(secret)
+	if len(plain) == 0 {
+		return nil, fmt.Errorf("secret %s has no data", secret.Name)
+	}
+
+	encrypted := make(map[string]string, len(plain))
+	for _, k := range slices.Sorted(maps.Keys(plain)) {
+		enc, err := agecrypt.Encrypt(plain[k], parsed...)
+		if err != nil {
+			return nil, fmt.Errorf("encrypt %s: %w", k, err)
+		}
+		encrypted[k] = enc
+	}
+
+	return &securityv1alpha1.SealedAge{
+		TypeMeta: metav1.TypeMeta{APIVersion: securityv1alpha1.GroupVersion.String(), Kind: "SealedAge"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        secret.Name,
+			Namespace:   secret.Namespace,
+			Annotations: map[string]string{securityv1alpha1.SealedRecipientsAnnotation: strings.Join(names, ",")},
+		},
+		Spec: securityv1alpha1.SealedAgeSpec{
+			EncryptedData: encrypted,
+			Template:      templateOf(secret),
+		},
+	}, nil
+}
+
+// secretData merges the data and stringData of secret, stringData wins.
+func secretData(secret *corev1.Secret) map[string][]byte {
+	plain := maps.Clone(secret.Data)
+	if plain == nil {
+		plain = map[string][]byte{}
+	}
+	for k, v := range secret.StringData {
+		plain[k] = []byte(v)
+	}
+	return plain
+}
+
+// templateOf returns the SealedAge template reproducing the type, labels,
+// annotations and immutable field of secret.
+func templateOf(secret *corev1.Secret) securityv1alpha1.SealedAgeTemplate {
+	annotations := maps.Clone(secret.Annotations)
+	delete(annotations, lastAppliedAnnotation)
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	labels := maps.Clone(secret.Labels)
+	if len(labels) == 0 {
+		labels = nil
+	}
+	return securityv1alpha1.SealedAgeTemplate{
+		Metadata: securityv1alpha1.SealedAgeTemplateMetadata{
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Type:      string(secret.Type),
+		Immutable: secret.Immutable,
+	}
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sealage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	age "filippo.io/age"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+	"github.com/callmewhatuwant/sealed-age-operator/internal/agecrypt"
+)
+
+const secretManifests = `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+  namespace: team
+  labels:
+    app: db
+  annotations:
+    kubectl.kubernetes.io/last-applied-configuration: '{"stringData":{"password":"hunter2"}}'
+    owner: team-a
+type: kubernetes.io/basic-auth
+immutable: true
+data:
+  username: YWRtaW4=
+  password: b2xk
+stringData:
+  password: hunter2
+---
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: api
+stringData:
+  token: abc
+`
+
+// decrypt opens an armored value with id.
+func decrypt(armored string, id age.Identity) string {
+	ring := &agecrypt.Keyring{}
+	ring.Add("key", id)
+	plain, _, err := ring.Decrypt(armored)
+	Expect(err).NotTo(HaveOccurred())
+	return string(plain)
+}
+
+var _ = Describe("Seal", func() {
+	var id *age.X25519Identity
+
+	BeforeEach(func() {
+		var err error
+		id, err = age.GenerateX25519Identity()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("seals every Secret of a multi-document manifest", func() {
+		secrets, err := ReadSecrets(strings.NewReader(secretManifests))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(secrets).To(HaveLen(2))
+
+		sa, err := Seal(&secrets[0], id.Recipient().String())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sa.APIVersion).To(Equal("security.age.io/v1alpha1"))
+		Expect(sa.Kind).To(Equal("SealedAge"))
+		Expect(sa.Name).To(Equal("db"))
+		Expect(sa.Namespace).To(Equal("team"))
+		Expect(sa.Annotations).To(HaveKeyWithValue(securityv1alpha1.SealedRecipientsAnnotation, id.Recipient().String()))
+		Expect(sa.Spec.EncryptedData).To(HaveLen(2))
+		Expect(decrypt(sa.Spec.EncryptedData["username"], id)).To(Equal("admin"))
+		By("letting stringData win over data")
+		Expect(decrypt(sa.Spec.EncryptedData["password"], id)).To(Equal("hunter2"))
+
+		tmpl := sa.Spec.Template
+		Expect(tmpl.Type).To(Equal("kubernetes.io/basic-auth"))
+		Expect(*tmpl.Immutable).To(BeTrue())
+		Expect(tmpl.Metadata.Labels).To(Equal(map[string]string{"app": "db"}))
+		By("dropping the last-applied annotation, it holds the plaintext")
+		Expect(tmpl.Metadata.Annotations).To(Equal(map[string]string{"owner": "team-a"}))
+	})
+
+	It("rejects other kinds and Secrets without data", func() {
+		_, err := ReadSecrets(strings.NewReader("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: x\n"))
+		Expect(err).To(MatchError(ContainSubstring("expected a v1 Secret")))
+		_, err = ReadSecrets(strings.NewReader("---\n"))
+		Expect(err).To(MatchError("no Secret found"))
+
+		empty := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "empty"}}
+		_, err = Seal(empty, id.Recipient().String())
+		Expect(err).To(MatchError(ContainSubstring("has no data")))
+	})
+
+	It("marshals manifests that round-trip into the API types", func() {
+		secrets, err := ReadSecrets(strings.NewReader(secretManifests))
+		Expect(err).NotTo(HaveOccurred())
+		var sealed []securityv1alpha1.SealedAge
+		for i := range secrets {
+			sa, err := Seal(&secrets[i], id.Recipient().String())
+			Expect(err).NotTo(HaveOccurred())
+			sealed = append(sealed, *sa)
+		}
+
+		out, err := Marshal(sealed, FormatYAML)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).NotTo(ContainSubstring("status"))
+		Expect(string(out)).NotTo(ContainSubstring("creationTimestamp"))
+		Expect(string(out)).NotTo(ContainSubstring("{}"))
+		docs := strings.Split(string(out), "\n---\n")
+		Expect(docs).To(HaveLen(2))
+		var back securityv1alpha1.SealedAge
+		Expect(yaml.UnmarshalStrict([]byte(docs[1]), &back)).To(Succeed())
+		Expect(decrypt(back.Spec.EncryptedData["token"], id)).To(Equal("abc"))
+
+		out, err = Marshal(sealed[:1], FormatJSON)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(yaml.UnmarshalStrict(out, &back)).To(Succeed())
+		Expect(back.Name).To(Equal("db"))
+		out, err = Marshal(sealed, FormatJSON)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(ContainSubstring(`"kind": "List"`))
+
+		_, err = ParseFormat("toml")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("reads recipients from the recipients endpoint and ConfigMap", func() {
+		file := fmt.Sprintf("# age-key-1 (active, created 2025-01-01T00:00:00Z)\n%s\n", id.Recipient())
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(file))
+		}))
+		defer srv.Close()
+		recipients, err := RecipientsFromURL(context.Background(), srv.URL+"/recipients")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recipients).To(Equal([]string{id.Recipient().String()}))
+
+		c := fake.NewClientBuilder().WithObjects(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "age-recipients", Namespace: "team"},
+			Data:       map[string]string{"recipients": file},
+		}).Build()
+		recipients, err = RecipientsFromConfigMap(context.Background(), c,
+			types.NamespacedName{Namespace: "team", Name: "age-recipients"})
+		Expect(err).NotTo(HaveOccurred())
+		_, parsed, err := ParseRecipients(recipients)
+		Expect(err).NotTo(HaveOccurred())
+		enc, err := agecrypt.Encrypt([]byte("x"), parsed...)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(decrypt(enc, id)).To(Equal("x"))
+	})
+})
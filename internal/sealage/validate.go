@@ -0,0 +1,389 @@
+/*
+Copyright 2025.
+Licensed under the Apache License, Version 2.0 (the "License");
+*/
+
+package sealage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	age "filippo.io/age"
+	"golang.org/x/crypto/ssh"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"k8s.io/kube-openapi/pkg/validation/strfmt"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+	"sigs.k8s.io/yaml"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+	"github.com/callmewhatuwant/sealed-age-operator/config/crd"
+	"github.com/callmewhatuwant/sealed-age-operator/internal/agecrypt"
+)
+
+// Severity of a validation Finding. Only errors fail validation.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Finding is a single validation problem.
+type Finding struct {
+	File     string   `json:"file,omitempty"`
+	Object   string   `json:"object,omitempty"` // namespace/name of the SealedAge
+	Field    string   `json:"field,omitempty"`  // encryptedData field
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+func (f Finding) String() string {
+	parts := []string{}
+	for _, p := range []string{f.File, f.Object, f.Field} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return fmt.Sprintf("%s: %s: %s", strings.Join(parts, ": "), f.Severity, f.Message)
+}
+
+// Result is the outcome of validating a set of files.
+type Result struct {
+	Files      int       `json:"files"`
+	SealedAges int       `json:"sealedAges"`
+	Errors     int       `json:"errors"`
+	Warnings   int       `json:"warnings"`
+	Findings   []Finding `json:"findings"`
+}
+
+func (r *Result) add(f Finding) {
+	switch f.Severity {
+	case SeverityError:
+		r.Errors++
+	case SeverityWarning:
+		r.Warnings++
+	}
+	r.Findings = append(r.Findings, f)
+}
+
+// Validator checks SealedAge manifests offline.
+type Validator struct {
+	// Recipients, if set, must include a recipient of every key-sealed value.
+	Recipients *RecipientSet
+	// RequireX25519 requires an X25519 stanza on every key-sealed value.
+	RequireX25519 bool
+
+	schema *spec.Schema
+}
+
+// NewValidator returns a Validator using the schema of the embedded SealedAge CRD.
+func NewValidator() (*Validator, error) {
+	var def apiextensionsv1.CustomResourceDefinition
+	if err := yaml.Unmarshal(crd.SealedAge, &def); err != nil {
+		return nil, fmt.Errorf("failed to parse the SealedAge CRD: %w", err)
+	}
+	for _, v := range def.Spec.Versions {
+		if v.Name != securityv1alpha1.GroupVersion.Version || v.Schema == nil {
+			continue
+		}
+		b, err := json.Marshal(v.Schema.OpenAPIV3Schema)
+		if err != nil {
+			return nil, err
+		}
+		schema := &spec.Schema{}
+		if err := json.Unmarshal(b, schema); err != nil {
+			return nil, fmt.Errorf("failed to parse the SealedAge schema: %w", err)
+		}
+		return &Validator{RequireX25519: true, schema: schema}, nil
+	}
+	return nil, fmt.Errorf("the SealedAge CRD has no %s schema", securityv1alpha1.GroupVersion.Version)
+}
+
+// ValidatePaths validates every .yaml, .yml and .json file under paths
+// (files or directories, walked recursively). Documents of other kinds are skipped.
+func (v *Validator) ValidatePaths(paths ...string) (*Result, error) {
+	rep := &Result{Findings: []Finding{}}
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != root && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			switch filepath.Ext(path) {
+			case ".yaml", ".yml", ".json":
+			default:
+				if path != root {
+					return nil
+				}
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = f.Close() }()
+			rep.Files++
+			v.validateFile(path, f, rep)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rep, nil
+}
+
+// validateFile validates every SealedAge document in r.
+func (v *Validator) validateFile(path string, r io.Reader, rep *Result) {
+	dec := utilyaml.NewYAMLOrJSONDecoder(r, 4096)
+	for n := 1; ; n++ {
+		var doc map[string]interface{}
+		if err := dec.Decode(&doc); errors.Is(err, io.EOF) {
+			return
+		} else if err != nil {
+			rep.add(Finding{File: path, Severity: SeverityError, Message: fmt.Sprintf("document %d: %v", n, err)})
+			return
+		}
+		if doc["kind"] != "SealedAge" {
+			continue
+		}
+		rep.SealedAges++
+		for _, f := range v.validateDocument(doc) {
+			f.File = path
+			rep.add(f)
+		}
+	}
+}
+
+// validateDocument checks one SealedAge document.
+func (v *Validator) validateDocument(doc map[string]interface{}) []Finding {
+	var findings []Finding
+	md, _ := doc["metadata"].(map[string]interface{})
+	name, _ := md["name"].(string)
+	if ns, _ := md["namespace"].(string); ns != "" {
+		name = ns + "/" + name
+	}
+	report := func(severity Severity, field, format string, args ...interface{}) {
+		findings = append(findings, Finding{Object: name, Field: field, Severity: severity,
+			Message: fmt.Sprintf(format, args...)})
+	}
+	fail := func(field, format string, args ...interface{}) {
+		report(SeverityError, field, format, args...)
+	}
+
+	if doc["apiVersion"] != securityv1alpha1.GroupVersion.String() {
+		fail("", "apiVersion %v is not %s", doc["apiVersion"], securityv1alpha1.GroupVersion)
+		return findings
+	}
+	if name == "" {
+		fail("", "metadata.name is required")
+	}
+	res := validate.NewSchemaValidator(v.schema, nil, "", strfmt.Default).Validate(doc)
+	for _, err := range res.Errors {
+		fail("", "%v", err)
+	}
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		fail("", "%v", err)
+		return findings
+	}
+	// The schema doesn't reject unknown fields, the API server prunes them.
+	// Only look for them once the schema passed, type errors show up twice otherwise.
+	if len(res.Errors) == 0 {
+		strict := json.NewDecoder(bytes.NewReader(b))
+		strict.DisallowUnknownFields()
+		if err := strict.Decode(&securityv1alpha1.SealedAge{}); err != nil {
+			fail("", "%v", err)
+		}
+	}
+	var sa securityv1alpha1.SealedAge
+	if err := json.Unmarshal(b, &sa); err != nil {
+		return findings
+	}
+
+	recorded := false
+	if v.Recipients != nil {
+		var msg string
+		msg, recorded = v.Recipients.checkRecorded(&sa)
+		if msg != "" {
+			fail("", "%s", msg)
+		}
+	}
+	for _, field := range slices.Sorted(maps.Keys(sa.Spec.EncryptedData)) {
+		_, passphrase := sa.Spec.PassphraseRefs[field]
+		msg, unchecked := v.checkValue(sa.Spec.EncryptedData[field], passphrase)
+		switch {
+		case msg != "":
+			fail(field, "%s", msg)
+		case unchecked > 0 && recorded:
+			report(SeverityWarning, field, "%d X25519 or plugin stanza(s) not checked against the recipients file: "+
+				"they don't name their recipient", unchecked)
+		case unchecked > 0:
+			// Nothing names the recipients of this value, it may be sealed to any key.
+			fail(field, "%d X25519 or plugin stanza(s) can't be checked against the recipients file "+
+				"without the %s annotation", unchecked, securityv1alpha1.SealedRecipientsAnnotation)
+		}
+	}
+	return findings
+}
+
+// checkValue checks a single encryptedData value; it returns the problem, if
+// any, and the number of stanzas that couldn't be checked against the recipients.
+func (v *Validator) checkValue(enc string, passphrase bool) (string, int) {
+	stanzas, err := agecrypt.ParseHeader(enc)
+	if err != nil {
+		return fmt.Sprintf("not an age file: %v", err), 0
+	}
+	if _, err := io.Copy(io.Discard, agecrypt.Reader(enc)); err != nil {
+		return fmt.Sprintf("invalid armor: %v", err), 0
+	}
+
+	types := map[string]bool{}
+	for _, s := range stanzas {
+		types[s.Type] = true
+	}
+	if passphrase {
+		if !types["scrypt"] {
+			return "field has a passphraseRef but no scrypt stanza", 0
+		}
+		return "", 0
+	}
+	if types["scrypt"] {
+		return "passphrase-encrypted field without a passphraseRef", 0
+	}
+	if v.RequireX25519 && !types["X25519"] {
+		return "no X25519 recipient stanza", 0
+	}
+	if v.Recipients == nil {
+		return "", 0
+	}
+	switch sealed, unchecked := v.Recipients.sealedTo(stanzas); {
+	case unchecked > 0:
+		return "", unchecked
+	case !sealed:
+		return "not sealed to any of the listed recipients", 0
+	}
+	return "", 0
+}
+
+// RecipientSet is a parsed recipients file used to check which recipients
+// values are sealed to. SSH stanzas carry a tag of the public key and can be
+// matched exactly; X25519 and plugin stanzas don't name their recipient, so
+// values relying on them are only checked through the SealedRecipientsAnnotation.
+type RecipientSet struct {
+	names   map[string]bool
+	sshTags map[string]bool
+	opaque  int
+}
+
+// NewRecipientSet returns the set of recipients, e.g. from RecipientsFromFile.
+func NewRecipientSet(recipients []string) (*RecipientSet, error) {
+	set := &RecipientSet{names: map[string]bool{}, sshTags: map[string]bool{}}
+	for _, line := range recipients {
+		set.names[normalizeRecipient(line)] = true
+		if !strings.HasPrefix(line, "ssh-") {
+			set.opaque++
+			continue
+		}
+		pk, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return nil, fmt.Errorf("recipient %q: %w", line, err)
+		}
+		set.sshTags[pk.Type()+" "+sshTag(pk)] = true
+	}
+	if len(set.sshTags) == 0 && set.opaque == 0 {
+		return nil, errors.New("no recipients found")
+	}
+	return set, nil
+}
+
+// sealedTo reports whether stanzas include one of the ssh recipients. Without
+// a matching ssh stanza, X25519 and plugin stanzas may still belong to an
+// X25519 or plugin recipient of the set; unchecked counts them then.
+func (s *RecipientSet) sealedTo(stanzas []*age.Stanza) (sealed bool, unchecked int) {
+	opaque := 0
+	for _, st := range stanzas {
+		switch st.Type {
+		case "ssh-ed25519", "ssh-rsa":
+			if len(st.Args) > 0 && s.sshTags[st.Type+" "+st.Args[0]] {
+				return true, 0
+			}
+		case "scrypt":
+		default:
+			opaque++
+		}
+	}
+	if s.opaque == 0 {
+		return false, 0
+	}
+	return false, opaque
+}
+
+// checkRecorded checks the recipients sa records in the
+// SealedRecipientsAnnotation against the set plus spec.recipients, the
+// recipients the operator would seal it to: at least one of them must be
+// recorded, and every recorded recipient must be one of them. It returns the
+// problem, if any, and whether sa has the annotation at all.
+func (s *RecipientSet) checkRecorded(sa *securityv1alpha1.SealedAge) (string, bool) {
+	annotation, ok := sa.Annotations[securityv1alpha1.SealedRecipientsAnnotation]
+	if !ok {
+		return "", false
+	}
+	recorded := map[string]bool{}
+	for _, r := range strings.Split(annotation, ",") {
+		if r = normalizeRecipient(r); r != "" {
+			recorded[r] = true
+		}
+	}
+	listed := maps.Clone(s.names)
+	for _, r := range sa.Spec.Recipients {
+		listed[normalizeRecipient(r)] = true
+	}
+
+	if extra := difference(recorded, listed); len(extra) > 0 {
+		return fmt.Sprintf("%s annotation lists recipients not in the recipients file: %s",
+			securityv1alpha1.SealedRecipientsAnnotation, strings.Join(extra, ", ")), true
+	}
+	if len(recorded) == 0 {
+		return fmt.Sprintf("%s annotation lists none of the recipients in the recipients file",
+			securityv1alpha1.SealedRecipientsAnnotation), true
+	}
+	return "", true
+}
+
+// difference returns the sorted keys of a that are not in b.
+func difference(a, b map[string]bool) []string {
+	var out []string
+	for k := range a {
+		if !b[k] {
+			out = append(out, k)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// sshTag is the key tag agessh puts in the first stanza argument.
+func sshTag(pk ssh.PublicKey) string {
+	h := sha256.Sum256(pk.Marshal())
+	return base64.RawStdEncoding.EncodeToString(h[:4])
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sealage
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	age "filippo.io/age"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/ssh"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	securityv1alpha1 "github.com/callmewhatuwant/sealed-age-operator/api/v1alpha1"
+)
+
+var _ = Describe("Validate", func() {
+	var (
+		dir string
+		id  *age.X25519Identity
+		v   *Validator
+	)
+
+	// writeSealed seals a one-field Secret to recipients and writes it to dir/file.
+	writeSealed := func(file, name string, recipients ...string) {
+		sa, err := Seal(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team"},
+			StringData: map[string]string{"password": "hunter2"},
+		}, recipients...)
+		Expect(err).NotTo(HaveOccurred())
+		out, err := Marshal([]securityv1alpha1.SealedAge{*sa}, FormatYAML)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.MkdirAll(filepath.Dir(filepath.Join(dir, file)), 0o755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, file), out, 0o644)).To(Succeed())
+	}
+
+	// newSSHRecipient returns an authorized_keys line of a new ed25519 key.
+	newSSHRecipient := func() string {
+		pub, _, err := ed25519.GenerateKey(rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		pk, err := ssh.NewPublicKey(pub)
+		Expect(err).NotTo(HaveOccurred())
+		return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pk)))
+	}
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		var err error
+		id, err = age.GenerateX25519Identity()
+		Expect(err).NotTo(HaveOccurred())
+		v, err = NewValidator()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("accepts valid SealedAges and skips other documents", func() {
+		writeSealed("apps/db.yaml", "db", id.Recipient().String())
+		Expect(os.WriteFile(filepath.Join(dir, "cm.yaml"),
+			[]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: x\n"), 0o644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "README.md"), []byte("# not yaml: ["), 0o644)).To(Succeed())
+
+		rep, err := v.ValidatePaths(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rep.Files).To(Equal(2))
+		Expect(rep.SealedAges).To(Equal(1))
+		Expect(rep.Findings).To(BeEmpty())
+	})
+
+	It("reports schema violations, unknown fields and invalid ciphertext", func() {
+		Expect(os.WriteFile(filepath.Join(dir, "bad.yaml"), []byte(`
+apiVersion: security.age.io/v1alpha1
+kind: SealedAge
+metadata:
+  name: enum
+spec:
+  resealPolicy: Sometimes
+  encryptedData:
+    a: not age
+---
+apiVersion: security.age.io/v1alpha1
+kind: SealedAge
+metadata:
+  name: unknown
+spec:
+  encryptedDta: {}
+  encryptedData: {}
+`), 0o644)).To(Succeed())
+
+		rep, err := v.ValidatePaths(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rep.SealedAges).To(Equal(2))
+		Expect(rep.Errors).To(Equal(3))
+		Expect(rep.Findings).To(ConsistOf(
+			HaveField("Message", ContainSubstring("spec.resealPolicy")),
+			And(HaveField("Field", "a"), HaveField("Message", ContainSubstring("not an age file"))),
+			And(HaveField("Object", "unknown"), HaveField("Message", ContainSubstring(`unknown field "encryptedDta"`))),
+		))
+	})
+
+	It("requires an X25519 stanza unless disabled", func() {
+		writeSealed("ssh.yaml", "ssh", newSSHRecipient())
+
+		rep, err := v.ValidatePaths(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rep.Findings).To(ConsistOf(HaveField("Message", "no X25519 recipient stanza")))
+
+		v.RequireX25519 = false
+		rep, err = v.ValidatePaths(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rep.Errors).To(BeZero())
+	})
+
+	It("checks values are sealed to a listed recipient", func() {
+		v.RequireX25519 = false
+		listed, other := newSSHRecipient(), newSSHRecipient()
+		writeSealed("listed.yaml", "listed", listed)
+		writeSealed("other.yaml", "other", other)
+
+		set, err := NewRecipientSet([]string{listed})
+		Expect(err).NotTo(HaveOccurred())
+		v.Recipients = set
+		rep, err := v.ValidatePaths(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rep.Findings).To(ConsistOf(
+			And(HaveField("Object", "team/other"), HaveField("Field", "password"),
+				HaveField("Message", "not sealed to any of the listed recipients")),
+			And(HaveField("Object", "team/other"), HaveField("Field", ""),
+				HaveField("Message", ContainSubstring("annotation lists recipients not in the recipients file"))),
+		))
+
+		By("rejecting X25519 stanzas when only ssh recipients are listed")
+		writeSealed("other.yaml", "other", id.Recipient().String())
+		rep, err = v.ValidatePaths(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rep.Findings).To(ContainElement(And(
+			HaveField("Object", "team/other"), HaveField("Message", "not sealed to any of the listed recipients"))))
+
+		By("not checking X25519 stanzas against X25519 recipients, with a warning per field")
+		Expect(os.Remove(filepath.Join(dir, "listed.yaml"))).To(Succeed())
+		set, err = NewRecipientSet([]string{id.Recipient().String()})
+		Expect(err).NotTo(HaveOccurred())
+		v.Recipients = set
+		rep, err = v.ValidatePaths(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rep.Errors).To(BeZero())
+		Expect(rep.Findings).To(ConsistOf(And(
+			HaveField("Object", "team/other"),
+			HaveField("Field", "password"),
+			HaveField("Severity", SeverityWarning),
+			HaveField("Message", HavePrefix("1 X25519 or plugin stanza(s) not checked")),
+		)))
+	})
+
+	It("fails when the recorded recipients differ from the recipients file", func() {
+		wrong, err := age.GenerateX25519Identity()
+		Expect(err).NotTo(HaveOccurred())
+		writeSealed("db.yaml", "db", wrong.Recipient().String())
+
+		set, err := NewRecipientSet([]string{id.Recipient().String()})
+		Expect(err).NotTo(HaveOccurred())
+		v.Recipients = set
+		rep, err := v.ValidatePaths(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rep.Errors).To(Equal(1))
+		Expect(rep.Findings).To(ContainElement(And(
+			HaveField("Severity", SeverityError),
+			HaveField("Message", "security.age.io/sealed-recipients annotation lists recipients not in the "+
+				"recipients file: "+wrong.Recipient().String()),
+		)))
+
+		By("accepting values sealed to some of the listed recipients")
+		other, err := age.GenerateX25519Identity()
+		Expect(err).NotTo(HaveOccurred())
+		writeSealed("db.yaml", "db", id.Recipient().String())
+		set, err = NewRecipientSet([]string{id.Recipient().String(), other.Recipient().String()})
+		Expect(err).NotTo(HaveOccurred())
+		v.Recipients = set
+		rep, err = v.ValidatePaths(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rep.Errors).To(BeZero())
+		Expect(rep.Warnings).To(Equal(1))
+
+		By("counting spec.recipients as expected, e.g. a break-glass key")
+		writeSealed("db.yaml", "db", id.Recipient().String(), wrong.Recipient().String())
+		b, err := os.ReadFile(filepath.Join(dir, "db.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		b = []byte(strings.Replace(string(b), "spec:\n", "spec:\n  recipients:\n  - "+wrong.Recipient().String()+"\n", 1))
+		Expect(os.WriteFile(filepath.Join(dir, "db.yaml"), b, 0o644)).To(Succeed())
+		rep, err = v.ValidatePaths(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rep.Errors).To(BeZero())
+	})
+
+	It("fails values it can't check without the recorded recipients", func() {
+		wrong, err := age.GenerateX25519Identity()
+		Expect(err).NotTo(HaveOccurred())
+		writeSealed("db.yaml", "db", wrong.Recipient().String())
+		b, err := os.ReadFile(filepath.Join(dir, "db.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		b = []byte(strings.Replace(string(b), securityv1alpha1.SealedRecipientsAnnotation, "example.com/unrelated", 1))
+		Expect(os.WriteFile(filepath.Join(dir, "db.yaml"), b, 0o644)).To(Succeed())
+
+		set, err := NewRecipientSet([]string{id.Recipient().String()})
+		Expect(err).NotTo(HaveOccurred())
+		v.Recipients = set
+		rep, err := v.ValidatePaths(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rep.Findings).To(ConsistOf(And(
+			HaveField("Field", "password"),
+			HaveField("Severity", SeverityError),
+			HaveField("Message", "1 X25519 or plugin stanza(s) can't be checked against the recipients file "+
+				"without the security.age.io/sealed-recipients annotation"),
+		)))
+	})
+})